@@ -0,0 +1,148 @@
+package main
+
+func init() {
+	RegisterHandler("member", payloadHandler(func() eventPayload { return &MemberEvent{} }))
+	RegisterHandler("repository", payloadHandler(func() eventPayload { return &RepositoryEvent{} }))
+	RegisterHandler("public", payloadHandler(func() eventPayload { return &PublicEvent{} }))
+	RegisterHandler("team_add", payloadHandler(func() eventPayload { return &TeamAddEvent{} }))
+	RegisterHandler("branch_protection_rule", payloadHandler(func() eventPayload { return &BranchProtectionRuleEvent{} }))
+	registerTemplates(map[string]messageTemplate{
+		"member": {
+			Message: "Collaborator {{.Member.Login}} {{.Action}} on {{.Repository.FullName}} by {{.Sender.Login}}{{with .Permission}} ({{.}}){{end}}.",
+			URL:     "{{.Repository.HTMLURL}}/settings/access",
+		},
+		"member:added": {
+			Message: "Collaborator {{.Member.Login}} added to {{.Repository.FullName}}{{with .Permission}} with {{.}} permission{{end}} by {{.Sender.Login}}.",
+			URL:     "{{.Repository.HTMLURL}}/settings/access",
+		},
+		"member:edited": {
+			Message: "Collaborator {{.Member.Login}} permission on {{.Repository.FullName}} changed" +
+				"{{with .Changes.OldPermission}} from {{.From}}{{end}}{{with .Permission}} to {{.}}{{end}} by {{.Sender.Login}}.",
+			URL: "{{.Repository.HTMLURL}}/settings/access",
+		},
+		"repository": {
+			Message: "Repository {{.Repository.FullName}} {{.Action}} by {{.Sender.Login}}.",
+			URL:     "{{.Repository.HTMLURL}}/settings",
+		},
+		"repository:renamed": {
+			Message: "Repository {{with .Changes.Repository}}{{.Name.From}}{{end}} renamed to {{.Repository.FullName}} by {{.Sender.Login}}.",
+			URL:     "{{.Repository.HTMLURL}}/settings",
+		},
+		"repository:transferred": {
+			Message: "Repository {{.Repository.FullName}} transferred{{with .PreviousOwner}} from {{.}}{{end}} by {{.Sender.Login}}.",
+			URL:     "{{.Repository.HTMLURL}}/settings",
+		},
+		"repository:publicized": {
+			Message: "Repository {{.Repository.FullName}} made public by {{.Sender.Login}}.",
+			URL:     "{{.Repository.HTMLURL}}/settings",
+		},
+		"repository:privatized": {
+			Message: "Repository {{.Repository.FullName}} made private by {{.Sender.Login}}.",
+			URL:     "{{.Repository.HTMLURL}}/settings",
+		},
+		"public": {
+			Message: "Repository {{.Repository.FullName}} made public by {{.Sender.Login}}.",
+			URL:     "{{.Repository.HTMLURL}}/settings",
+		},
+		"team_add": {
+			Message: "Team {{.Team.Name}} given{{with .Team.Permission}} {{.}}{{end}} access to {{.Repository.FullName}} by {{.Sender.Login}}.",
+			URL:     "{{.Repository.HTMLURL}}/settings/access",
+		},
+		"branch_protection_rule": {
+			Message: "Branch protection rule {{.Rule.Name}} {{.Action}} on {{.Repository.FullName}} by {{.Sender.Login}}.",
+			URL:     "{{.Repository.HTMLURL}}/settings/branches",
+		},
+	})
+}
+
+// MemberEvent is the payload of member events, sent when a collaborator is
+// added to or removed from a repository or their permission changes.
+type MemberEvent struct {
+	Event
+	Member  *User `json:"member" required:"true"`
+	Changes struct {
+		Permission *struct {
+			To string `json:"to"`
+		} `json:"permission"`
+		OldPermission *struct {
+			From string `json:"from"`
+		} `json:"old_permission"`
+	} `json:"changes"`
+}
+
+// Permission is the collaborator's new permission, if the payload says.
+func (e *MemberEvent) Permission() string {
+	if e.Changes.Permission == nil {
+		return ""
+	}
+	return e.Changes.Permission.To
+}
+
+// RepositoryEvent is the payload of repository events.
+type RepositoryEvent struct {
+	Event
+	Changes struct {
+		Repository *struct {
+			Name struct {
+				From string `json:"from"`
+			} `json:"name"`
+		} `json:"repository"`
+		Owner *struct {
+			From struct {
+				User         *User `json:"user"`
+				Organization *User `json:"organization"`
+			} `json:"from"`
+		} `json:"owner"`
+	} `json:"changes"`
+}
+
+// PreviousOwner is the account a transferred repository belonged to.
+func (e *RepositoryEvent) PreviousOwner() string {
+	if e.Changes.Owner == nil {
+		return ""
+	}
+	if owner := e.Changes.Owner.From.Organization; owner != nil {
+		return owner.Login
+	}
+	if owner := e.Changes.Owner.From.User; owner != nil {
+		return owner.Login
+	}
+	return ""
+}
+
+// PublicEvent is the payload of public events, sent when a private
+// repository is made public.
+type PublicEvent struct {
+	Event
+}
+
+// Team is a team in an organization.
+type Team struct {
+	Name       string `json:"name" required:"true"`
+	Slug       string `json:"slug"`
+	Permission string `json:"permission"`
+	HTMLURL    string `json:"html_url"`
+}
+
+// TeamAddEvent is the payload of team_add events, sent when a team is given
+// access to a repository.
+type TeamAddEvent struct {
+	Event
+	Team *Team `json:"team" required:"true"`
+}
+
+// BranchProtectionRule is the protection applied to branches matching a
+// pattern.
+type BranchProtectionRule struct {
+	Name string `json:"name" required:"true"`
+}
+
+// BranchProtectionRuleEvent is the payload of branch_protection_rule events.
+type BranchProtectionRuleEvent struct {
+	Event
+	Rule *BranchProtectionRule `json:"rule" required:"true"`
+}
+
+func (e *BranchProtectionRuleEvent) describe(meta *Metadata) {
+	meta.Ref = e.Rule.Name
+}
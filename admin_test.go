@@ -0,0 +1,89 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const adminRepositoryJSON = `"repository":{"id":186853002,"node_id":"MDEwOlJlcG9zaXRvcnkxODY4NTMwMDI=","name":"Hello-World","full_name":"Codertocat/Hello-World","private":false,"owner":{"login":"Codertocat","id":21031067,"type":"User","site_admin":false},"html_url":"https://github.com/Codertocat/Hello-World","description":null,"fork":false,"url":"https://api.github.com/repos/Codertocat/Hello-World","default_branch":"master"},"sender":{"login":"Codertocat","id":21031067,"node_id":"MDQ6VXNlcjIxMDMxMDY3","html_url":"https://github.com/Codertocat","type":"User","site_admin":false}`
+
+func TestMember(t *testing.T) {
+	name := "member"
+	json := `{"action":"added","member":{"login":"Octocat","id":1,"node_id":"MDQ6VXNlcjU4MzIzMQ==","html_url":"https://github.com/Octocat","type":"User","site_admin":false},"changes":{"permission":{"to":"write"}},` + adminRepositoryJSON + `}`
+	note, _, err := parseEvent(name, json)
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+
+	assert(t, note.Message == "Collaborator Octocat added to Codertocat/Hello-World with write permission by Codertocat.", "Message incorrect")
+	assert(t, note.URL == "https://github.com/Codertocat/Hello-World/settings/access", "URL incorrect")
+
+	json = `{"action":"edited","member":{"login":"Octocat","id":1,"type":"User"},"changes":{"old_permission":{"from":"write"},"permission":{"to":"admin"}},` + adminRepositoryJSON + `}`
+	note, _, err = parseEvent(name, json)
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+	assert(t, note.Message == "Collaborator Octocat permission on Codertocat/Hello-World changed from write to admin by Codertocat.", "Edited message incorrect")
+}
+
+func TestRepository(t *testing.T) {
+	name := "repository"
+	for _, tc := range []struct{ json, message string }{
+		{`{"action":"archived",` + adminRepositoryJSON + `}`, "Repository Codertocat/Hello-World archived by Codertocat."},
+		{`{"action":"renamed","changes":{"repository":{"name":{"from":"Hello"}}},` + adminRepositoryJSON + `}`, "Repository Hello renamed to Codertocat/Hello-World by Codertocat."},
+		{`{"action":"transferred","changes":{"owner":{"from":{"organization":{"login":"Octocoders","id":38302899,"type":"Organization"}}}},` + adminRepositoryJSON + `}`, "Repository Codertocat/Hello-World transferred from Octocoders by Codertocat."},
+		{`{"action":"privatized",` + adminRepositoryJSON + `}`, "Repository Codertocat/Hello-World made private by Codertocat."},
+	} {
+		note, _, err := parseEvent(name, tc.json)
+
+		if err != nil {
+			t.Errorf("Error: %s", err)
+		}
+
+		assert(t, note.Message == tc.message, "Message incorrect: "+note.Message)
+		assert(t, note.URL == "https://github.com/Codertocat/Hello-World/settings", "URL incorrect")
+	}
+}
+
+func TestPublic(t *testing.T) {
+	name := "public"
+	note, _, err := parseEvent(name, `{`+adminRepositoryJSON+`}`)
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+
+	assert(t, note.Message == "Repository Codertocat/Hello-World made public by Codertocat.", "Message incorrect")
+}
+
+func TestTeamAdd(t *testing.T) {
+	name := "team_add"
+	json := `{"team":{"name":"github","id":3253328,"node_id":"MDQ6VGVhbTMyNTMzMjg=","slug":"github","description":"Open-source team","privacy":"secret","url":"https://api.github.com/teams/3253328","html_url":"https://github.com/orgs/Octocoders/teams/github","permission":"pull"},` + adminRepositoryJSON + `}`
+	note, _, err := parseEvent(name, json)
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+
+	assert(t, note.Message == "Team github given pull access to Codertocat/Hello-World by Codertocat.", "Message incorrect")
+	assert(t, note.URL == "https://github.com/Codertocat/Hello-World/settings/access", "URL incorrect")
+}
+
+func TestBranchProtectionRule(t *testing.T) {
+	name := "branch_protection_rule"
+	json := `{"action":"deleted","rule":{"id":21796960,"repository_id":186853002,"name":"release/*","created_at":"2021-11-03T12:07:40Z","updated_at":"2021-11-03T12:07:40Z","pull_request_reviews_enforcement_level":"off","required_approving_review_count":0,"dismiss_stale_reviews_on_push":false,"require_code_owner_review":false,"allow_force_pushes_enforcement_level":"off","allow_deletions_enforcement_level":"off","required_status_checks_enforcement_level":"off","required_status_checks":[],"admin_enforced":false},` + adminRepositoryJSON + `}`
+	note, meta, err := parseEvent(name, json)
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+
+	assert(t, note.Message == "Branch protection rule release/* deleted on Codertocat/Hello-World by Codertocat.", "Message incorrect")
+	assert(t, note.URL == "https://github.com/Codertocat/Hello-World/settings/branches", "URL incorrect")
+	assert(t, meta.Ref == "release/*", "Metadata incorrect")
+
+	_, _, err = parseEvent(name, strings.Replace(json, `"name":"release/*",`, "", 1))
+	assert(t, err != nil && err.Error() == "Unable to get rule.name: missing from payload", "missing rule name not reported")
+}
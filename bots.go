@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// What to do with deliveries sent by bots.
+const (
+	botIgnore = "ignore"
+	botLower  = "lower"
+	botNotify = "notify"
+)
+
+func validBotMode(mode string) bool {
+	return mode == botIgnore || mode == botLower || mode == botNotify
+}
+
+// isBot reports whether the delivery was sent by a GitHub App or other
+// automation rather than a person.
+func isBot(meta *Metadata) bool {
+	return meta.SenderType == "Bot" || strings.HasSuffix(meta.Sender, "[bot]")
+}
+
+// botMode returns how the delivery should be treated: botNotify for people
+// and allowed bots, otherwise the mode for the event and action, falling
+// back to the event and then the configured mode.
+func botMode(config *settings, meta *Metadata) string {
+	if !isBot(meta) {
+		return botNotify
+	}
+	if config.botAllow[meta.Sender] || config.botAllow[strings.TrimSuffix(meta.Sender, "[bot]")] {
+		return botNotify
+	}
+	if mode, ok := config.botEvents[meta.Event+":"+meta.Action]; ok {
+		return mode
+	}
+	if mode, ok := config.botEvents[meta.Event]; ok {
+		return mode
+	}
+	if config.botMode == "" {
+		return botNotify
+	}
+	return config.botMode
+}
+
+// parseBotEvent parses a bot mode for an event given on the command line as
+// "event[:action]=mode".
+func parseBotEvent(spec string) (string, string, error) {
+	i := strings.LastIndex(spec, "=")
+	if i <= 0 {
+		return "", "", fmt.Errorf("Invalid bot event %s, expected event[:action]=mode", spec)
+	}
+	if !validBotMode(spec[i+1:]) {
+		return "", "", fmt.Errorf("Invalid mode in bot event %s, expected ignore, lower or notify", spec)
+	}
+	return spec[:i], spec[i+1:], nil
+}
@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestBotMode(t *testing.T) {
+	config := &settings{
+		botMode:   botIgnore,
+		botAllow:  map[string]bool{"release-bot": true},
+		botEvents: map[string]string{"pull_request": botLower, "pull_request:opened": botNotify},
+	}
+
+	for _, tc := range []struct {
+		meta Metadata
+		mode string
+	}{
+		{Metadata{Event: "push", Sender: "octocat", SenderType: "User"}, botNotify},
+		{Metadata{Event: "push", Sender: "ci", SenderType: "Bot"}, botIgnore},
+		{Metadata{Event: "push", Sender: "renovate[bot]"}, botIgnore},
+		{Metadata{Event: "release", Sender: "release-bot[bot]", SenderType: "Bot"}, botNotify},
+		{Metadata{Event: "pull_request", Action: "closed", Sender: "dependabot[bot]", SenderType: "Bot"}, botLower},
+		{Metadata{Event: "pull_request", Action: "opened", Sender: "dependabot[bot]", SenderType: "Bot"}, botNotify},
+	} {
+		mode := botMode(config, &tc.meta)
+		assert(t, mode == tc.mode, "Mode incorrect for "+tc.meta.Sender+": "+mode)
+	}
+
+	assert(t, botMode(&settings{}, &Metadata{Sender: "ci", SenderType: "Bot"}) == botNotify, "bots not notified by default")
+}
+
+func TestParseBotEventInvalid(t *testing.T) {
+	for _, spec := range []string{"pull_request", "=notify", "pull_request=always"} {
+		_, _, err := parseBotEvent(spec)
+		assert(t, err != nil, "invalid bot event accepted: "+spec)
+	}
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/justone/pmb/api"
+)
+
+func init() {
+	RegisterHandler("check_run", templateHandler(decodeCheckRun))
+	RegisterHandler("check_suite", templateHandler(decodeCheckSuite))
+	RegisterHandler("status", templateHandler(decodeStatus))
+	registerTemplates(map[string]messageTemplate{
+		"check_run": {
+			Message: "Check {{.CheckRun.Name}} {{or .CheckRun.Conclusion .CheckRun.Status}} for {{shortSHA .CheckRun.HeadSHA}}" +
+				"{{with .Branch}} on {{.}}{{end}} in {{.Repository.FullName}}.",
+			URL: "{{or .CheckRun.DetailsURL .CheckRun.HTMLURL}}",
+		},
+		"check_suite": {
+			Message: "Check suite{{with .CheckSuite.App}} {{.Name}}{{end}} {{or .CheckSuite.Conclusion .CheckSuite.Status}} for {{shortSHA .CheckSuite.HeadSHA}}" +
+				"{{with .CheckSuite.HeadBranch}} on {{.}}{{end}} in {{.Repository.FullName}}.",
+			URL: "{{.Repository.HTMLURL}}/commit/{{.CheckSuite.HeadSHA}}/checks",
+		},
+		"status": {
+			Message: "Status {{.Context}} {{.State}} for {{shortSHA .SHA}}{{with .Branch}} on {{.}}{{end}} in {{.Repository.FullName}}" +
+				"{{with .Description}}: {{truncate . 40}}{{end}}",
+			URL: "{{or .TargetURL .Commit.HTMLURL}}",
+		},
+		"status_summary": {
+			Message: "Statuses {{.State}} for {{shortSHA .SHA}}{{with .Branch}} on {{.}}{{end}} in {{.Repository.FullName}}: {{.Counts}}" +
+				"{{with .Failed}} ({{join . \", \"}}){{end}}",
+			URL: "{{.URL}}",
+		},
+	})
+}
+
+// App is the GitHub App that created a check.
+type App struct {
+	Name string `json:"name"`
+	Slug string `json:"slug"`
+}
+
+// CheckSuite is the set of check runs an app created for a commit.
+type CheckSuite struct {
+	HeadBranch string `json:"head_branch"`
+	HeadSHA    string `json:"head_sha" required:"true"`
+	Status     string `json:"status"`
+	Conclusion string `json:"conclusion"`
+	App        *App   `json:"app"`
+}
+
+// CheckRun is a single check.
+type CheckRun struct {
+	Name       string      `json:"name" required:"true"`
+	HeadSHA    string      `json:"head_sha" required:"true"`
+	Status     string      `json:"status"`
+	Conclusion string      `json:"conclusion"`
+	HTMLURL    string      `json:"html_url"`
+	DetailsURL string      `json:"details_url"`
+	CheckSuite *CheckSuite `json:"check_suite"`
+	App        *App        `json:"app"`
+}
+
+// CheckRunEvent is the payload of check_run events.
+type CheckRunEvent struct {
+	Event
+	CheckRun *CheckRun `json:"check_run" required:"true"`
+}
+
+// Branch is the branch the checked commit is on, if known.
+func (e *CheckRunEvent) Branch() string {
+	if e.CheckRun.CheckSuite == nil {
+		return ""
+	}
+	return e.CheckRun.CheckSuite.HeadBranch
+}
+
+func (e *CheckRunEvent) describe(meta *Metadata) {
+	meta.Ref = e.Branch()
+	meta.State = e.CheckRun.Conclusion
+}
+
+// CheckSuiteEvent is the payload of check_suite events.
+type CheckSuiteEvent struct {
+	Event
+	CheckSuite *CheckSuite `json:"check_suite" required:"true"`
+}
+
+func (e *CheckSuiteEvent) describe(meta *Metadata) {
+	meta.Ref = e.CheckSuite.HeadBranch
+	meta.State = e.CheckSuite.Conclusion
+}
+
+// StatusEvent is the payload of status events, sent when the status of a
+// commit changes.
+type StatusEvent struct {
+	Event
+	SHA         string `json:"sha" required:"true"`
+	Context     string `json:"context" required:"true"`
+	State       string `json:"state" required:"true"`
+	Description string `json:"description"`
+	TargetURL   string `json:"target_url"`
+	Commit      struct {
+		HTMLURL string `json:"html_url"`
+	} `json:"commit"`
+	Branches []struct {
+		Name string `json:"name"`
+	} `json:"branches"`
+}
+
+// Branch is the first branch containing the commit, if any.
+func (e *StatusEvent) Branch() string {
+	if len(e.Branches) == 0 {
+		return ""
+	}
+	return e.Branches[0].Name
+}
+
+func (e *StatusEvent) describe(meta *Metadata) {
+	// Statuses have no action; the state stands in for it, as it does for
+	// the summaries sent with --status-settle.
+	meta.Action = e.State
+	meta.Ref = e.Branch()
+	meta.State = e.State
+}
+
+func decodeCheckRun(payload []byte) (interface{}, *Metadata, error) {
+	var event CheckRunEvent
+	if err := decodeEvent(payload, &event); err != nil {
+		return nil, nil, err
+	}
+	meta := newMetadata(event.Event)
+	if event.Action != "completed" {
+		return nil, meta, nil
+	}
+	return &event, meta, nil
+}
+
+func decodeCheckSuite(payload []byte) (interface{}, *Metadata, error) {
+	var event CheckSuiteEvent
+	if err := decodeEvent(payload, &event); err != nil {
+		return nil, nil, err
+	}
+	meta := newMetadata(event.Event)
+	if event.Action != "completed" {
+		return nil, meta, nil
+	}
+	return &event, meta, nil
+}
+
+func decodeStatus(payload []byte) (interface{}, *Metadata, error) {
+	var event StatusEvent
+	if err := decodeEvent(payload, &event); err != nil {
+		return nil, nil, err
+	}
+	meta := newMetadata(event.Event)
+	if statuses != nil {
+		statuses.Add(&event)
+		return nil, meta, nil
+	}
+	return &event, meta, nil
+}
+
+// statuses collapses bursts of statuses, when enabled.
+var statuses *statusAggregator
+
+// statusMaxWait bounds how long a burst waits for pending statuses.
+const statusMaxWait = time.Hour
+
+// statusAggregator collects the statuses reported for each commit and sends
+// a single summary once none has been pending for the settle period.
+type statusAggregator struct {
+	mu     sync.Mutex
+	settle time.Duration
+	bursts map[string]*statusBurst
+	emit   func(*pmb.Notification, *Metadata)
+}
+
+type statusBurst struct {
+	started  time.Time
+	deadline time.Time
+	timer    *time.Timer
+	statuses map[string]*StatusEvent
+}
+
+func newStatusAggregator(settle time.Duration, emit func(*pmb.Notification, *Metadata)) *statusAggregator {
+	return &statusAggregator{
+		settle: settle,
+		bursts: make(map[string]*statusBurst),
+		emit:   emit,
+	}
+}
+
+// Add records a status, restarting the settle period for its commit.
+func (a *statusAggregator) Add(event *StatusEvent) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	key := event.Repository.FullName + "@" + event.SHA
+	// The deadline is taken before the timer is started, so that the timer
+	// never fires before it.
+	deadline := time.Now().Add(a.settle)
+	burst, ok := a.bursts[key]
+	if !ok {
+		burst = &statusBurst{
+			started:  time.Now(),
+			statuses: make(map[string]*StatusEvent),
+		}
+		burst.timer = time.AfterFunc(a.settle, func() { a.fire(key, burst) })
+		a.bursts[key] = burst
+	} else {
+		burst.timer.Reset(a.settle)
+	}
+	burst.deadline = deadline
+	burst.statuses[event.Context] = event
+}
+
+func (a *statusAggregator) fire(key string, burst *statusBurst) {
+	a.mu.Lock()
+	// The burst may have been sent already, or the timer reset by a status
+	// that arrived while this was waiting for the lock; the timer then runs
+	// again once the new settle period is over.
+	if a.bursts[key] != burst || time.Now().Before(burst.deadline) {
+		a.mu.Unlock()
+		return
+	}
+	if burst.pending() && time.Since(burst.started) < statusMaxWait {
+		burst.deadline = time.Now().Add(a.settle)
+		burst.timer.Reset(a.settle)
+		a.mu.Unlock()
+		return
+	}
+	delete(a.bursts, key)
+	a.mu.Unlock()
+
+	summary := newStatusSummary(burst)
+	meta := newMetadata(summary.latest.Event)
+	meta.Event = "status"
+	meta.Action = summary.State
+	meta.State = summary.State
+	meta.Ref = summary.Branch
+	meta.Data = summary
+
+	note, err := renderNotification("status_summary", summary.State, summary)
+	if err != nil {
+		logrus.Warnf("Unable to summarize statuses for %s: %s", key, err)
+		return
+	}
+	a.emit(note, meta)
+}
+
+func (b *statusBurst) pending() bool {
+	for _, status := range b.statuses {
+		if status.State == "pending" {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusSummary is the data the status_summary template is rendered with.
+type StatusSummary struct {
+	Repository *Repository
+	Sender     *User
+	SHA        string
+	Branch     string
+	State      string
+	Counts     string
+	Failed     []string
+	URL        string
+	Statuses   []*StatusEvent
+
+	latest *StatusEvent
+}
+
+func newStatusSummary(burst *statusBurst) *StatusSummary {
+	var contexts []string
+	for context := range burst.statuses {
+		contexts = append(contexts, context)
+	}
+	sort.Strings(contexts)
+
+	summary := &StatusSummary{State: "success"}
+	counts := make(map[string]int)
+	var states []string
+	for _, context := range contexts {
+		status := burst.statuses[context]
+		summary.Statuses = append(summary.Statuses, status)
+		if counts[status.State] == 0 {
+			states = append(states, status.State)
+		}
+		counts[status.State]++
+
+		switch status.State {
+		case "failure", "error":
+			summary.State = "failure"
+			summary.Failed = append(summary.Failed, context)
+			if summary.URL == "" {
+				summary.URL = status.TargetURL
+			}
+		case "pending":
+			if summary.State == "success" {
+				summary.State = "pending"
+			}
+		}
+		summary.latest = status
+	}
+
+	var parts []string
+	for _, state := range states {
+		parts = append(parts, fmt.Sprintf("%d %s", counts[state], state))
+	}
+	summary.Counts = strings.Join(parts, ", ")
+
+	summary.Repository = summary.latest.Repository
+	summary.Sender = summary.latest.Sender
+	summary.SHA = summary.latest.SHA
+	summary.Branch = summary.latest.Branch()
+	if summary.URL == "" {
+		summary.URL = summary.latest.Commit.HTMLURL
+	}
+	return summary
+}
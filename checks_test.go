@@ -0,0 +1,169 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/justone/pmb/api"
+)
+
+const checkRunJSON = `{"action":"completed","check_run":{"id":128620228,"node_id":"MDg6Q2hlY2tSdW4xMjg2MjAyMjg=","head_sha":"ec26c3e57ca3a959ca5aad62de7213c562f8c821","external_id":"","url":"https://api.github.com/repos/Codertocat/Hello-World/check-runs/128620228","html_url":"https://github.com/Codertocat/Hello-World/runs/128620228","details_url":"https://octocoders.io/builds/128620228","status":"completed","conclusion":"failure","started_at":"2019-05-15T15:21:12Z","completed_at":"2019-05-15T15:21:45Z","output":{"title":null,"summary":null,"text":null,"annotations_count":0,"annotations_url":"https://api.github.com/repos/Codertocat/Hello-World/check-runs/128620228/annotations"},"name":"Octocoders-linter","check_suite":{"id":118578147,"node_id":"MDEwOkNoZWNrU3VpdGUxMTg1NzgxNDc=","head_branch":"changes","head_sha":"ec26c3e57ca3a959ca5aad62de7213c562f8c821","status":"completed","conclusion":"failure","url":"https://api.github.com/repos/Codertocat/Hello-World/check-suites/118578147","before":"6113728f27ae82c7b1a177c8d03f9e96e0adf246","after":"ec26c3e57ca3a959ca5aad62de7213c562f8c821","pull_requests":[],"app":{"id":29310,"node_id":"MDM6QXBwMjkzMTA=","owner":{"login":"Octocoders","id":38302899,"type":"Organization"},"name":"octocoders-linter","description":"","external_url":"https://octocoders.io","html_url":"https://github.com/apps/octocoders-linter","created_at":"2019-04-19T19:36:24Z","updated_at":"2019-04-19T19:36:56Z"},"created_at":"2019-05-15T15:20:31Z","updated_at":"2019-05-15T15:21:14Z"},"app":{"id":29310,"node_id":"MDM6QXBwMjkzMTA=","name":"octocoders-linter","slug":"octocoders-linter"},"pull_requests":[]},"repository":{"id":186853002,"node_id":"MDEwOlJlcG9zaXRvcnkxODY4NTMwMDI=","name":"Hello-World","full_name":"Codertocat/Hello-World","private":false,"owner":{"login":"Codertocat","id":21031067,"type":"User","site_admin":false},"html_url":"https://github.com/Codertocat/Hello-World","description":null,"fork":false,"url":"https://api.github.com/repos/Codertocat/Hello-World","default_branch":"master"},"sender":{"login":"Codertocat","id":21031067,"node_id":"MDQ6VXNlcjIxMDMxMDY3","html_url":"https://github.com/Codertocat","type":"User","site_admin":false}}`
+
+const checkSuiteJSON = `{"action":"completed","check_suite":{"id":118578147,"node_id":"MDEwOkNoZWNrU3VpdGUxMTg1NzgxNDc=","head_branch":"changes","head_sha":"ec26c3e57ca3a959ca5aad62de7213c562f8c821","status":"completed","conclusion":"success","url":"https://api.github.com/repos/Codertocat/Hello-World/check-suites/118578147","before":"6113728f27ae82c7b1a177c8d03f9e96e0adf246","after":"ec26c3e57ca3a959ca5aad62de7213c562f8c821","pull_requests":[],"app":{"id":29310,"node_id":"MDM6QXBwMjkzMTA=","name":"octocoders-linter","slug":"octocoders-linter","html_url":"https://github.com/apps/octocoders-linter"},"created_at":"2019-05-15T15:20:31Z","updated_at":"2019-05-15T15:21:14Z","latest_check_runs_count":1,"check_runs_url":"https://api.github.com/repos/Codertocat/Hello-World/check-suites/118578147/check-runs","head_commit":{"id":"ec26c3e57ca3a959ca5aad62de7213c562f8c821","tree_id":"31b122c26a97cf9af023e9ddab94a82c6e77b0ea","message":"Update README.md","timestamp":"2019-05-15T15:20:30Z","author":{"name":"Codertocat","email":"21031067+Codertocat@users.noreply.github.com"},"committer":{"name":"Codertocat","email":"21031067+Codertocat@users.noreply.github.com"}}},"repository":{"id":186853002,"node_id":"MDEwOlJlcG9zaXRvcnkxODY4NTMwMDI=","name":"Hello-World","full_name":"Codertocat/Hello-World","private":false,"owner":{"login":"Codertocat","id":21031067,"type":"User","site_admin":false},"html_url":"https://github.com/Codertocat/Hello-World","description":null,"fork":false,"url":"https://api.github.com/repos/Codertocat/Hello-World","default_branch":"master"},"sender":{"login":"Codertocat","id":21031067,"node_id":"MDQ6VXNlcjIxMDMxMDY3","html_url":"https://github.com/Codertocat","type":"User","site_admin":false}}`
+
+const statusJSON = `{"id":6805126730,"sha":"6113728f27ae82c7b1a177c8d03f9e96e0adf246","name":"Codertocat/Hello-World","target_url":"https://ci.example.com/builds/1234","context":"ci/build","description":"The build failed after 2 minutes of compiling and running the test suite","state":"failure","commit":{"sha":"6113728f27ae82c7b1a177c8d03f9e96e0adf246","node_id":"MDY6Q29tbWl0MTg2ODUzMDAyOjYxMTM3MjhmMjdhZTgyYzdiMWExNzdjOGQwM2Y5ZTk2ZTBhZGYyNDY=","commit":{"author":{"name":"Codertocat","email":"21031067+Codertocat@users.noreply.github.com","date":"2019-05-15T15:19:25Z"},"message":"Initial commit"},"url":"https://api.github.com/repos/Codertocat/Hello-World/commits/6113728f27ae82c7b1a177c8d03f9e96e0adf246","html_url":"https://github.com/Codertocat/Hello-World/commit/6113728f27ae82c7b1a177c8d03f9e96e0adf246"},"branches":[{"name":"master","commit":{"sha":"6113728f27ae82c7b1a177c8d03f9e96e0adf246","url":"https://api.github.com/repos/Codertocat/Hello-World/commits/6113728f27ae82c7b1a177c8d03f9e96e0adf246"},"protected":false}],"created_at":"2019-05-15T15:20:55+00:00","updated_at":"2019-05-15T15:20:55+00:00","repository":{"id":186853002,"node_id":"MDEwOlJlcG9zaXRvcnkxODY4NTMwMDI=","name":"Hello-World","full_name":"Codertocat/Hello-World","private":false,"owner":{"login":"Codertocat","id":21031067,"type":"User","site_admin":false},"html_url":"https://github.com/Codertocat/Hello-World","description":null,"fork":false,"url":"https://api.github.com/repos/Codertocat/Hello-World","default_branch":"master"},"sender":{"login":"Codertocat","id":21031067,"node_id":"MDQ6VXNlcjIxMDMxMDY3","html_url":"https://github.com/Codertocat","type":"User","site_admin":false}}`
+
+func TestCheckRun(t *testing.T) {
+	name := "check_run"
+	note, meta, err := parseEvent(name, checkRunJSON)
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+
+	assert(t, note.Message == "Check Octocoders-linter failure for ec26c3e on changes in Codertocat/Hello-World.", "Message incorrect")
+	assert(t, note.URL == "https://octocoders.io/builds/128620228", "URL incorrect")
+	assert(t, meta.Ref == "changes" && meta.State == "failure", "Metadata incorrect")
+
+	note, _, err = parseEvent(name, strings.Replace(checkRunJSON, `"action":"completed"`, `"action":"created"`, 1))
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+	assert(t, note == nil, "created check run notified")
+}
+
+func TestCheckSuite(t *testing.T) {
+	name := "check_suite"
+	note, _, err := parseEvent(name, checkSuiteJSON)
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+
+	assert(t, note.Message == "Check suite octocoders-linter success for ec26c3e on changes in Codertocat/Hello-World.", "Message incorrect")
+	assert(t, note.URL == "https://github.com/Codertocat/Hello-World/commit/ec26c3e57ca3a959ca5aad62de7213c562f8c821/checks", "URL incorrect")
+}
+
+func TestStatus(t *testing.T) {
+	name := "status"
+	note, meta, err := parseEvent(name, statusJSON)
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+
+	assert(t, note.Message == "Status ci/build failure for 6113728 on master in Codertocat/Hello-World: The build failed after 2 minutes of comp...", "Message incorrect")
+	assert(t, note.URL == "https://ci.example.com/builds/1234", "URL incorrect")
+	assert(t, meta.Ref == "master" && meta.Action == "failure" && meta.State == "failure", "Metadata incorrect")
+}
+
+func TestStatusCollapsed(t *testing.T) {
+	type emitted struct {
+		note *pmb.Notification
+		meta *Metadata
+	}
+	out := make(chan emitted, 2)
+	statuses = newStatusAggregator(20*time.Millisecond, func(note *pmb.Notification, meta *Metadata) {
+		out <- emitted{note, meta}
+	})
+	defer func() { statuses = nil }()
+
+	pending := strings.Replace(statusJSON, `"state":"failure"`, `"state":"pending"`, 1)
+	lint := strings.Replace(statusJSON, `"context":"ci/build"`, `"context":"ci/lint"`, 1)
+	lint = strings.Replace(lint, `"state":"failure"`, `"state":"success"`, 1)
+	for _, payload := range []string{pending, lint, statusJSON} {
+		note, _, err := parseEvent("status", payload)
+		if err != nil {
+			t.Errorf("Error: %s", err)
+		}
+		assert(t, note == nil, "status not collapsed")
+	}
+
+	select {
+	case e := <-out:
+		assert(t, e.note.Message == "Statuses failure for 6113728 on master in Codertocat/Hello-World: 1 failure, 1 success (ci/build)", "Message incorrect")
+		assert(t, e.note.URL == "https://ci.example.com/builds/1234", "URL incorrect")
+		assert(t, e.meta.Event == "status" && e.meta.Action == "failure" && e.meta.State == "failure" && e.meta.Repository == "Codertocat/Hello-World", "Metadata incorrect")
+	case <-time.After(time.Second):
+		t.Fatalf("Error: no summary sent")
+	}
+
+	select {
+	case <-out:
+		t.Errorf("Error: summary sent twice")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestStatusWaitsForPending(t *testing.T) {
+	out := make(chan *pmb.Notification, 1)
+	statuses = newStatusAggregator(10*time.Millisecond, func(note *pmb.Notification, meta *Metadata) {
+		out <- note
+	})
+	defer func() { statuses = nil }()
+
+	parseEvent("status", strings.Replace(statusJSON, `"state":"failure"`, `"state":"pending"`, 1))
+
+	select {
+	case <-out:
+		t.Fatalf("Error: summary sent while pending")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	parseEvent("status", strings.Replace(statusJSON, `"state":"failure"`, `"state":"success"`, 1))
+
+	select {
+	case note := <-out:
+		assert(t, note.Message == "Statuses success for 6113728 on master in Codertocat/Hello-World: 1 success", "Message incorrect")
+	case <-time.After(time.Second):
+		t.Fatalf("Error: no summary sent")
+	}
+}
+
+func TestStatusFireAfterReset(t *testing.T) {
+	out := make(chan *pmb.Notification, 2)
+	a := newStatusAggregator(20*time.Millisecond, func(note *pmb.Notification, meta *Metadata) {
+		out <- note
+	})
+
+	var event StatusEvent
+	if err := decodeEvent([]byte(statusJSON), &event); err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	key := "Codertocat/Hello-World@" + event.SHA
+	a.Add(&event)
+	first := a.bursts[key]
+
+	// A timer that fired just before the burst was extended must not send
+	// it early.
+	a.Add(&event)
+	a.fire(key, first)
+	select {
+	case <-out:
+		t.Fatalf("Error: summary sent before settling")
+	default:
+	}
+
+	select {
+	case <-out:
+	case <-time.After(time.Second):
+		t.Fatalf("Error: no summary sent")
+	}
+
+	// Nor may the timer of a burst that was already sent send a newer one.
+	a.Add(&event)
+	first.deadline = time.Time{}
+	a.fire(key, first)
+	select {
+	case <-out:
+		t.Fatalf("Error: newer burst sent by stale timer")
+	default:
+	}
+	select {
+	case <-out:
+	case <-time.After(time.Second):
+		t.Fatalf("Error: no summary sent")
+	}
+}
@@ -0,0 +1,368 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"sort"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// Config is the layout of the configuration file. It extends the command
+// line options, which it is merged with, and is re-read on SIGHUP or when
+// the file changes.
+type Config struct {
+	Ignore        []string                   `yaml:"ignore"`
+	Level         *float64                   `yaml:"level"`
+	Levels        map[string]float64         `yaml:"levels"`
+	Secrets       []SecretConfig             `yaml:"secrets"`
+	SecretFile    string                     `yaml:"secret_file"`
+	TemplatesFile string                     `yaml:"templates_file"`
+	Templates     map[string]messageTemplate `yaml:"templates"`
+	Rules         []RuleConfig               `yaml:"rules"`
+
+	EnvLevels      map[string]float64 `yaml:"environment_levels"`
+	SeverityLevels map[string]float64 `yaml:"severity_levels"`
+
+	WorkflowActions     []string `yaml:"workflow_actions"`
+	WorkflowConclusions []string `yaml:"workflow_conclusions"`
+
+	IssueAssignees []string `yaml:"issue_assignees"`
+	PushCommits    *int     `yaml:"push_commits"`
+
+	Mentions     []string `yaml:"mentions"`
+	MentionLevel *float64 `yaml:"mention_level"`
+
+	BotMode   string            `yaml:"bot_mode"`
+	BotLevel  *float64          `yaml:"bot_level"`
+	BotAllow  []string          `yaml:"bot_allow"`
+	BotEvents map[string]string `yaml:"bot_events"`
+}
+
+// SecretConfig is a webhook secret in the configuration file.
+type SecretConfig struct {
+	Secret  string `yaml:"secret"`
+	Label   string `yaml:"label"`
+	Expires string `yaml:"expires"`
+}
+
+// settings is the configuration in effect, built from the command line and
+// the configuration file. It is replaced as a whole on reload and must not
+// be modified once in use.
+type settings struct {
+	ignore    map[string]bool
+	level     float64
+	levels    []levelMapping
+	secrets   []webhookSecret
+	templates templateSet
+	rules     []rule
+
+	envLevels      []environmentLevel
+	severityLevels map[string]float64
+
+	workflowActions     map[string]bool
+	workflowConclusions map[string]bool
+
+	issueAssignees map[string]bool
+	pushCommits    int
+
+	mentions     *mentions
+	mentionLevel float64
+
+	botMode   string
+	botLevel  float64
+	botAllow  map[string]bool
+	botEvents map[string]string
+}
+
+var (
+	defaultWorkflowActions     = []string{"completed"}
+	defaultWorkflowConclusions = []string{"failure", "timed_out", "cancelled", "action_required", "startup_failure", "stale"}
+)
+
+// stringSet builds a set from the first non-empty list.
+func stringSet(lists ...[]string) map[string]bool {
+	set := make(map[string]bool)
+	for _, list := range lists {
+		if len(list) == 0 {
+			continue
+		}
+		for _, item := range list {
+			set[item] = true
+		}
+		break
+	}
+	return set
+}
+
+var current atomic.Value
+
+// currentSettings returns the settings in effect.
+func currentSettings() *settings {
+	if s, ok := current.Load().(*settings); ok {
+		return s
+	}
+	return &settings{level: opts.Level}
+}
+
+func readConfig(path string) (*Config, error) {
+	config := &Config{}
+	if path == "" {
+		return config, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read config: %s", err)
+	}
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, fmt.Errorf("Unable to parse config: %s", err)
+	}
+	return config, nil
+}
+
+// loadSettings combines the command line options with the configuration
+// file at path, validating the result.
+func loadSettings(path string) (*settings, error) {
+	config, err := readConfig(path)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &settings{
+		ignore: make(map[string]bool),
+		level:  opts.Level,
+	}
+
+	for _, u := range opts.Ignore {
+		s.ignore[u] = true
+	}
+	for _, u := range config.Ignore {
+		s.ignore[u] = true
+	}
+
+	if config.Level != nil {
+		s.level = *config.Level
+	}
+	if s.level < 0 {
+		return nil, fmt.Errorf("Invalid level %g", s.level)
+	}
+	for _, spec := range opts.Levels {
+		m, err := parseLevelMapping(spec)
+		if err != nil {
+			return nil, err
+		}
+		s.levels = append(s.levels, m)
+	}
+	var keys []string
+	for key := range config.Levels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		m, err := parseLevelKey(key, config.Levels[key])
+		if err != nil {
+			return nil, err
+		}
+		s.levels = append(s.levels, m)
+	}
+	for _, spec := range opts.EnvironmentLevels {
+		e, err := parseEnvironmentLevel(spec)
+		if err != nil {
+			return nil, err
+		}
+		s.envLevels = append(s.envLevels, e)
+	}
+	keys = nil
+	for key := range config.EnvLevels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		e, err := parseEnvironmentKey(key, config.EnvLevels[key])
+		if err != nil {
+			return nil, err
+		}
+		s.envLevels = append(s.envLevels, e)
+	}
+	s.severityLevels = make(map[string]float64)
+	for severity, level := range defaultSeverityLevels {
+		s.severityLevels[severity] = level
+	}
+	for _, spec := range opts.SeverityLevels {
+		severity, level, err := parseSeverityLevel(spec)
+		if err != nil {
+			return nil, err
+		}
+		s.severityLevels[severity] = level
+	}
+	for severity, level := range config.SeverityLevels {
+		if _, ok := defaultSeverityLevels[severity]; !ok || level < 0 {
+			return nil, fmt.Errorf("Invalid severity level %s=%g", severity, level)
+		}
+		s.severityLevels[severity] = level
+	}
+
+	secretFile := opts.SecretFile
+	if config.SecretFile != "" {
+		secretFile = config.SecretFile
+	}
+	s.secrets, err = loadSecrets(opts.Secrets, secretFile)
+	if err != nil {
+		return nil, err
+	}
+	for i, secret := range config.Secrets {
+		if secret.Secret == "" {
+			return nil, fmt.Errorf("Missing value for secret %d in config", i+1)
+		}
+		ws := webhookSecret{
+			Label: secret.Label,
+			Value: []byte(secret.Secret),
+		}
+		if ws.Label == "" {
+			ws.Label = fmt.Sprintf("config#%d", i+1)
+		}
+		if secret.Expires != "" {
+			ws.Expires, err = time.Parse(time.RFC3339, secret.Expires)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid expiry for secret %s: %s", ws.Label, err)
+			}
+		}
+		s.secrets = append(s.secrets, ws)
+	}
+
+	templatesFile := opts.Templates
+	if config.TemplatesFile != "" {
+		templatesFile = config.TemplatesFile
+	}
+	s.templates, err = loadTemplates(templatesFile)
+	if err != nil {
+		return nil, err
+	}
+	inline, err := compileTemplates(config.Templates)
+	if err != nil {
+		return nil, err
+	}
+	for key, tmpl := range inline {
+		s.templates[key] = tmpl
+	}
+
+	s.rules, err = compileRules(config.Rules)
+	if err != nil {
+		return nil, err
+	}
+
+	s.workflowActions = stringSet(config.WorkflowActions, opts.WorkflowActions, defaultWorkflowActions)
+	s.workflowConclusions = stringSet(config.WorkflowConclusions, opts.WorkflowConclusions, defaultWorkflowConclusions)
+	s.issueAssignees = stringSet(config.IssueAssignees, opts.IssueAssignees)
+	s.pushCommits = opts.PushCommits
+	if config.PushCommits != nil {
+		s.pushCommits = *config.PushCommits
+	}
+
+	handles := config.Mentions
+	if len(handles) == 0 {
+		handles = opts.Mentions
+	}
+	s.mentions = compileMentions(handles)
+	s.mentionLevel = opts.MentionLevel
+	if config.MentionLevel != nil {
+		s.mentionLevel = *config.MentionLevel
+	}
+	if s.mentionLevel < 0 {
+		return nil, fmt.Errorf("Invalid mention level %g", s.mentionLevel)
+	}
+
+	s.botMode = opts.Bots
+	if config.BotMode != "" {
+		s.botMode = config.BotMode
+	}
+	if s.botMode != "" && !validBotMode(s.botMode) {
+		return nil, fmt.Errorf("Invalid bot mode %s, expected ignore, lower or notify", s.botMode)
+	}
+	s.botLevel = opts.BotLevel
+	if config.BotLevel != nil {
+		s.botLevel = *config.BotLevel
+	}
+	if s.botLevel < 0 {
+		return nil, fmt.Errorf("Invalid bot level %g", s.botLevel)
+	}
+	s.botAllow = stringSet(config.BotAllow, opts.BotAllow)
+	s.botEvents = make(map[string]string)
+	for _, spec := range opts.BotEvents {
+		event, mode, err := parseBotEvent(spec)
+		if err != nil {
+			return nil, err
+		}
+		s.botEvents[event] = mode
+	}
+	for event, mode := range config.BotEvents {
+		if !validBotMode(mode) {
+			return nil, fmt.Errorf("Invalid bot mode %s for %s, expected ignore, lower or notify", mode, event)
+		}
+		s.botEvents[event] = mode
+	}
+
+	return s, nil
+}
+
+// applySettings puts s into effect, warning about anything that needs
+// attention.
+func applySettings(s *settings) {
+	if len(s.secrets) == 0 {
+		logrus.Warnf("No webhook secret configured, deliveries will not be verified")
+	}
+	for _, secret := range s.secrets {
+		if secret.expired(time.Now()) {
+			logrus.Warnf("Webhook secret %s expired at %s", secret.Label, secret.Expires)
+		}
+	}
+	current.Store(s)
+}
+
+func reloadSettings(path string) {
+	s, err := loadSettings(path)
+	if err != nil {
+		logrus.Warnf("Keeping previous config, reload failed: %s", err)
+		return
+	}
+	applySettings(s)
+	logrus.Infof("Config reloaded")
+}
+
+// watchConfig reloads the settings on SIGHUP, and whenever the config file
+// is modified if poll is non-zero.
+func watchConfig(path string, poll time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	var tick <-chan time.Time
+	var modified time.Time
+	if path != "" && poll > 0 {
+		tick = time.Tick(poll)
+		if info, err := os.Stat(path); err == nil {
+			modified = info.ModTime()
+		}
+	}
+
+	for {
+		select {
+		case <-hup:
+			logrus.Infof("Received SIGHUP, reloading config")
+			reloadSettings(path)
+		case <-tick:
+			info, err := os.Stat(path)
+			if err != nil || info.ModTime().Equal(modified) {
+				continue
+			}
+			modified = info.ModTime()
+			logrus.Infof("Config file %s changed, reloading", path)
+			reloadSettings(path)
+		}
+	}
+}
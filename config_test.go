@@ -0,0 +1,80 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func writeConfig(t *testing.T, contents string) string {
+	f, err := ioutil.TempFile("", "config")
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	f.WriteString(contents)
+	f.Close()
+	return f.Name()
+}
+
+func TestLoadSettings(t *testing.T) {
+	opts.Ignore = []string{"baxterthehacker"}
+	opts.Level = 4
+	defer func() { opts.Ignore, opts.Level = nil, 0 }()
+
+	path := writeConfig(t, `
+ignore:
+  - otheruser
+level: 5
+secrets:
+  - secret: s3cret
+    label: current
+  - secret: old
+    expires: 2016-11-01T00:00:00Z
+templates:
+  watch:
+    message: "Star from {{.Sender.Login}}"
+`)
+	defer os.Remove(path)
+
+	s, err := loadSettings(path)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+
+	assert(t, s.ignore["baxterthehacker"] && s.ignore["otheruser"], "ignore lists not merged")
+	assert(t, s.level == 5, "level not overridden")
+	assert(t, len(s.secrets) == 2, "secrets not loaded")
+	assert(t, s.secrets[0].Label == "current" && s.secrets[1].Label == "config#2", "secret labels incorrect")
+	assert(t, !s.secrets[1].Expires.IsZero(), "secret expiry not parsed")
+	_, ok := s.templates["watch"]
+	assert(t, ok, "templates not loaded")
+}
+
+func TestLoadSettingsInvalid(t *testing.T) {
+	for _, contents := range []string{
+		"ignor:\n  - otheruser\n",
+		"secrets:\n  - label: missing\n",
+		"templates:\n  push:\n    message: \"{{.Ref\"\n",
+		"level: -1\n",
+		"bot_mode: quiet\n",
+		"bot_events:\n  pull_request: always\n",
+	} {
+		path := writeConfig(t, contents)
+		_, err := loadSettings(path)
+		os.Remove(path)
+
+		assert(t, err != nil, "invalid config accepted: "+contents)
+	}
+}
+
+func TestReloadKeepsPrevious(t *testing.T) {
+	previous := &settings{level: 3}
+	current.Store(previous)
+	defer current.Store(&settings{})
+
+	path := writeConfig(t, "level: [\n")
+	defer os.Remove(path)
+	reloadSettings(path)
+
+	assert(t, currentSettings() == previous, "settings replaced by invalid config")
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+// deliveryCache remembers the X-GitHub-Delivery GUIDs seen recently, so that
+// redelivered hooks don't produce duplicate notifications. Entries expire
+// after ttl and the oldest are evicted once there are more than size of
+// them. When a path is given, GUIDs are appended to it so they survive a
+// restart, and it is rewritten with only the live ones once most of its
+// lines have been pruned.
+type deliveryCache struct {
+	mu    sync.Mutex
+	ttl   time.Duration
+	size  int
+	seen  map[string]time.Time
+	order []string
+
+	// inflight holds the deliveries reserved by requests still being
+	// handled.
+	inflight map[string]bool
+
+	path  string
+	file  *os.File
+	lines int
+}
+
+func newDeliveryCache(ttl time.Duration, size int, path string) (*deliveryCache, error) {
+	c := &deliveryCache{
+		ttl:  ttl,
+		size: size,
+		seen: make(map[string]time.Time),
+
+		inflight: make(map[string]bool),
+	}
+	if path == "" {
+		return c, nil
+	}
+
+	c.path = path
+	if err := c.load(path, time.Now()); err != nil {
+		return nil, err
+	}
+	if err := c.compact(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// load reads previously seen deliveries from path.
+func (c *deliveryCache) load(path string, now time.Time) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("Unable to read delivery cache: %s", err)
+	}
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		seconds, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		if at := time.Unix(seconds, 0); now.Sub(at) < c.ttl {
+			c.add(fields[0], at)
+		}
+	}
+	f.Close()
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("Unable to read delivery cache: %s", err)
+	}
+	c.prune(now)
+	return nil
+}
+
+// compact rewrites the file with only the live deliveries and reopens it
+// for appending.
+func (c *deliveryCache) compact() error {
+	tmp := c.path + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("Unable to compact delivery cache: %s", err)
+	}
+	w := bufio.NewWriter(out)
+	for _, id := range c.order {
+		fmt.Fprintf(w, "%s %d\n", id, c.seen[id].Unix())
+	}
+	if err := w.Flush(); err != nil {
+		out.Close()
+		return fmt.Errorf("Unable to compact delivery cache: %s", err)
+	}
+	out.Close()
+	if err := os.Rename(tmp, c.path); err != nil {
+		return fmt.Errorf("Unable to compact delivery cache: %s", err)
+	}
+
+	file, err := os.OpenFile(c.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("Unable to open delivery cache: %s", err)
+	}
+	if c.file != nil {
+		c.file.Close()
+	}
+	c.file = file
+	c.lines = len(c.order)
+	return nil
+}
+
+// Seen reports whether id was delivered within the TTL.
+func (c *deliveryCache) Seen(id string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.prune(now)
+	_, ok := c.seen[id]
+	return ok
+}
+
+// Reserve claims id for a request about to handle it, reporting false if it
+// was delivered within the TTL or another request is handling it. A
+// reservation ends with Record once the delivery has been accepted, or
+// Release if it could not be, so that a redelivery can try again.
+func (c *deliveryCache) Reserve(id string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.prune(now)
+	if _, ok := c.seen[id]; ok || c.inflight[id] {
+		return false
+	}
+	c.inflight[id] = true
+	return true
+}
+
+// Release gives up the reservation of id, if it hasn't been recorded.
+func (c *deliveryCache) Release(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.inflight, id)
+}
+
+// Record remembers that id was delivered, once the delivery has been
+// accepted.
+func (c *deliveryCache) Record(id string, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.inflight, id)
+	if _, ok := c.seen[id]; ok {
+		return
+	}
+	c.add(id, now)
+	c.prune(now)
+
+	if c.file == nil {
+		return
+	}
+	if _, err := fmt.Fprintf(c.file, "%s %d\n", id, now.Unix()); err != nil {
+		logrus.Warnf("Unable to persist delivery %s: %s", id, err)
+	}
+	c.lines++
+
+	// Rewrite the file once it is mostly made of pruned deliveries, so that
+	// it stays within a small multiple of the cache size.
+	if c.lines > c.size && c.lines > 2*len(c.order) {
+		if err := c.compact(); err != nil {
+			logrus.Warnf("%s", err)
+		}
+	}
+}
+
+func (c *deliveryCache) add(id string, at time.Time) {
+	if _, ok := c.seen[id]; ok {
+		return
+	}
+	c.seen[id] = at
+	c.order = append(c.order, id)
+}
+
+// prune drops expired entries and the oldest ones beyond the size limit.
+// Entries are added in time order, so both are found at the front.
+func (c *deliveryCache) prune(now time.Time) {
+	drop := 0
+	for drop < len(c.order) {
+		id := c.order[drop]
+		if len(c.order)-drop <= c.size && now.Sub(c.seen[id]) < c.ttl {
+			break
+		}
+		delete(c.seen, id)
+		drop++
+	}
+	c.order = c.order[drop:]
+}
+
+func (c *deliveryCache) Close() error {
+	if c.file == nil {
+		return nil
+	}
+	return c.file.Close()
+}
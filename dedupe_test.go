@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeliveryCacheSeen(t *testing.T) {
+	cache, err := newDeliveryCache(time.Hour, 10, "")
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	now := time.Now()
+
+	assert(t, !cache.Seen("72d3162e-cc78-11e3-81ab-4c9367dc0958", now), "new delivery reported as seen")
+	cache.Record("72d3162e-cc78-11e3-81ab-4c9367dc0958", now)
+	assert(t, cache.Seen("72d3162e-cc78-11e3-81ab-4c9367dc0958", now.Add(time.Minute)), "redelivery not detected")
+	assert(t, !cache.Seen("72d3162e-cc78-11e3-81ab-4c9367dc0958", now.Add(2*time.Hour)), "expired delivery reported as seen")
+}
+
+func TestDeliveryCacheSize(t *testing.T) {
+	cache, err := newDeliveryCache(time.Hour, 2, "")
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	now := time.Now()
+
+	cache.Record("a", now)
+	cache.Record("b", now)
+	cache.Record("c", now)
+	assert(t, len(cache.seen) == 2, "cache not bounded")
+	assert(t, !cache.Seen("a", now), "oldest delivery not evicted")
+}
+
+func TestDeliveryCachePersisted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pmb-gh")
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "deliveries")
+
+	cache, err := newDeliveryCache(time.Hour, 10, path)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	cache.Record("a", time.Now())
+	cache.Record("b", time.Now().Add(-2*time.Hour))
+	cache.Close()
+
+	cache, err = newDeliveryCache(time.Hour, 10, path)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	defer cache.Close()
+	assert(t, cache.Seen("a", time.Now()), "delivery not restored")
+	assert(t, !cache.Seen("b", time.Now()), "expired delivery restored")
+}
+
+func TestDeliveryCacheCompacted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pmb-gh")
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "deliveries")
+
+	cache, err := newDeliveryCache(time.Hour, 3, path)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	defer cache.Close()
+	now := time.Now()
+	for i := 0; i < 100; i++ {
+		cache.Record(fmt.Sprintf("delivery-%d", i), now)
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	lines := strings.Count(string(data), "\n")
+	assert(t, lines <= 6, fmt.Sprintf("delivery cache file not compacted: %d lines", lines))
+	assert(t, strings.Contains(string(data), "delivery-99 "), "latest delivery not persisted")
+	assert(t, !strings.Contains(string(data), "delivery-0 "), "evicted delivery kept")
+}
+
+func TestDeliveryCacheReserve(t *testing.T) {
+	cache, err := newDeliveryCache(time.Hour, 10, "")
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	now := time.Now()
+
+	assert(t, cache.Reserve("a", now), "new delivery not reserved")
+	assert(t, !cache.Reserve("a", now), "delivery in flight reserved twice")
+	cache.Release("a")
+	assert(t, cache.Reserve("a", now), "released delivery not reserved again")
+	cache.Record("a", now)
+	cache.Release("a")
+	assert(t, !cache.Reserve("a", now), "recorded delivery reserved")
+}
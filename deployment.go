@@ -0,0 +1,73 @@
+package main
+
+func init() {
+	RegisterHandler("deployment", payloadHandler(func() eventPayload { return &DeploymentEvent{} }))
+	RegisterHandler("deployment_status", payloadHandler(func() eventPayload { return &DeploymentStatusEvent{} }))
+	registerTemplates(map[string]messageTemplate{
+		"deployment": {
+			Message: "Deployment of {{shortSHA .Deployment.SHA}}{{with .Deployment.Ref}} from {{.}}{{end}} to {{.Deployment.Environment}}" +
+				" on {{.Repository.FullName}} created by {{.Deployment.Creator.Login}}{{with .Deployment.Description}}: {{truncate . 40}}{{end}}.",
+			URL: "{{.Repository.HTMLURL}}/deployments",
+		},
+		"deployment_status": {
+			Message: "Deployment of {{shortSHA .Deployment.SHA}}{{with .Deployment.Ref}} from {{.}}{{end}} to {{.Environment}}" +
+				" on {{.Repository.FullName}}: {{.DeploymentStatus.State}}{{with .Deployment.Creator}} by {{.Login}}{{end}}" +
+				"{{with .DeploymentStatus.Description}} ({{truncate . 40}}){{end}}.",
+			URL: "{{or .DeploymentStatus.EnvironmentURL .DeploymentStatus.LogURL .DeploymentStatus.TargetURL (printf \"%s/deployments\" .Repository.HTMLURL)}}",
+		},
+	})
+}
+
+// Deployment is a request to deploy a ref to an environment.
+type Deployment struct {
+	SHA         string `json:"sha" required:"true"`
+	Ref         string `json:"ref"`
+	Task        string `json:"task"`
+	Environment string `json:"environment" required:"true"`
+	Description string `json:"description"`
+	Creator     *User  `json:"creator" required:"true"`
+}
+
+// DeploymentStatus is the state of a deployment.
+type DeploymentStatus struct {
+	State          string `json:"state" required:"true"`
+	Description    string `json:"description"`
+	Environment    string `json:"environment"`
+	EnvironmentURL string `json:"environment_url"`
+	LogURL         string `json:"log_url"`
+	TargetURL      string `json:"target_url"`
+	Creator        *User  `json:"creator"`
+}
+
+// DeploymentEvent is the payload of deployment events.
+type DeploymentEvent struct {
+	Event
+	Deployment *Deployment `json:"deployment" required:"true"`
+}
+
+func (e *DeploymentEvent) describe(meta *Metadata) {
+	meta.Ref = branch(e.Deployment.Ref)
+	meta.Environment = e.Deployment.Environment
+}
+
+// DeploymentStatusEvent is the payload of deployment_status events.
+type DeploymentStatusEvent struct {
+	Event
+	Deployment       *Deployment       `json:"deployment" required:"true"`
+	DeploymentStatus *DeploymentStatus `json:"deployment_status" required:"true"`
+}
+
+// Environment is the environment the status was reported for, which can
+// differ from the one originally deployed to.
+func (e *DeploymentStatusEvent) Environment() string {
+	if e.DeploymentStatus.Environment != "" {
+		return e.DeploymentStatus.Environment
+	}
+	return e.Deployment.Environment
+}
+
+func (e *DeploymentStatusEvent) describe(meta *Metadata) {
+	meta.Ref = branch(e.Deployment.Ref)
+	meta.State = e.DeploymentStatus.State
+	meta.Environment = e.Environment()
+}
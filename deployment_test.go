@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const deploymentJSON = `{"action":"created","deployment":{"url":"https://api.github.com/repos/Codertocat/Hello-World/deployments/145988746","id":145988746,"node_id":"MDEwOkRlcGxveW1lbnQxNDU5ODg3NDY=","sha":"f95f852bd8fca8fcc58a9a2d6c842781e32a215e","ref":"master","task":"deploy","payload":{},"original_environment":"production","environment":"production","description":"Weekly release","creator":{"login":"Codertocat","id":21031067,"node_id":"MDQ6VXNlcjIxMDMxMDY3","html_url":"https://github.com/Codertocat","type":"User","site_admin":false},"created_at":"2019-05-15T15:20:53Z","updated_at":"2019-05-15T15:20:53Z","statuses_url":"https://api.github.com/repos/Codertocat/Hello-World/deployments/145988746/statuses","repository_url":"https://api.github.com/repos/Codertocat/Hello-World","transient_environment":false,"production_environment":true},"repository":{"id":186853002,"node_id":"MDEwOlJlcG9zaXRvcnkxODY4NTMwMDI=","name":"Hello-World","full_name":"Codertocat/Hello-World","private":false,"owner":{"login":"Codertocat","id":21031067,"type":"User","site_admin":false},"html_url":"https://github.com/Codertocat/Hello-World","description":null,"fork":false,"url":"https://api.github.com/repos/Codertocat/Hello-World","default_branch":"master"},"sender":{"login":"Codertocat","id":21031067,"node_id":"MDQ6VXNlcjIxMDMxMDY3","html_url":"https://github.com/Codertocat","type":"User","site_admin":false}}`
+
+const deploymentStatusJSON = `{"action":"created","deployment_status":{"url":"https://api.github.com/repos/Codertocat/Hello-World/deployments/145988746/statuses/209916254","id":209916254,"node_id":"MDE2OkRlcGxveW1lbnRTdGF0dXMyMDk5MTYyNTQ=","state":"failure","creator":{"login":"deploy-bot","id":38302899,"type":"Bot","site_admin":false},"description":"","environment":"production","target_url":"https://ci.example.com/deploys/42","log_url":"https://ci.example.com/deploys/42","environment_url":"","created_at":"2019-05-15T15:20:55Z","updated_at":"2019-05-15T15:20:55Z","deployment_url":"https://api.github.com/repos/Codertocat/Hello-World/deployments/145988746","repository_url":"https://api.github.com/repos/Codertocat/Hello-World"},"deployment":{"url":"https://api.github.com/repos/Codertocat/Hello-World/deployments/145988746","id":145988746,"node_id":"MDEwOkRlcGxveW1lbnQxNDU5ODg3NDY=","sha":"f95f852bd8fca8fcc58a9a2d6c842781e32a215e","ref":"master","task":"deploy","payload":{},"original_environment":"production","environment":"production","description":null,"creator":{"login":"Codertocat","id":21031067,"node_id":"MDQ6VXNlcjIxMDMxMDY3","html_url":"https://github.com/Codertocat","type":"User","site_admin":false},"created_at":"2019-05-15T15:20:53Z","updated_at":"2019-05-15T15:20:53Z","statuses_url":"https://api.github.com/repos/Codertocat/Hello-World/deployments/145988746/statuses","repository_url":"https://api.github.com/repos/Codertocat/Hello-World"},"repository":{"id":186853002,"node_id":"MDEwOlJlcG9zaXRvcnkxODY4NTMwMDI=","name":"Hello-World","full_name":"Codertocat/Hello-World","private":false,"owner":{"login":"Codertocat","id":21031067,"type":"User","site_admin":false},"html_url":"https://github.com/Codertocat/Hello-World","description":null,"fork":false,"url":"https://api.github.com/repos/Codertocat/Hello-World","default_branch":"master"},"sender":{"login":"Codertocat","id":21031067,"node_id":"MDQ6VXNlcjIxMDMxMDY3","html_url":"https://github.com/Codertocat","type":"User","site_admin":false}}`
+
+func TestDeployment(t *testing.T) {
+	name := "deployment"
+	note, meta, err := parseEvent(name, deploymentJSON)
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+
+	assert(t, note.Message == "Deployment of f95f852 from master to production on Codertocat/Hello-World created by Codertocat: Weekly release.", "Message incorrect")
+	assert(t, note.URL == "https://github.com/Codertocat/Hello-World/deployments", "URL incorrect")
+	assert(t, meta.Environment == "production" && meta.Ref == "master", "Metadata incorrect")
+}
+
+func TestDeploymentStatus(t *testing.T) {
+	name := "deployment_status"
+	note, meta, err := parseEvent(name, deploymentStatusJSON)
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+
+	assert(t, note.Message == "Deployment of f95f852 from master to production on Codertocat/Hello-World: failure by Codertocat.", "Message incorrect")
+	assert(t, note.URL == "https://ci.example.com/deploys/42", "URL incorrect")
+	assert(t, meta.Environment == "production" && meta.State == "failure", "Metadata incorrect")
+
+	note, _, err = parseEvent(name, strings.Replace(deploymentStatusJSON, `"environment_url":""`, `"environment_url":"https://hello-world.example.com"`, 1))
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+	assert(t, note.URL == "https://hello-world.example.com", "environment URL not preferred")
+}
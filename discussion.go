@@ -0,0 +1,77 @@
+package main
+
+func init() {
+	RegisterHandler("discussion", payloadHandler(func() eventPayload { return &DiscussionEvent{} }))
+	RegisterHandler("discussion_comment", payloadHandler(func() eventPayload { return &DiscussionCommentEvent{} }))
+	registerTemplates(map[string]messageTemplate{
+		"discussion": {
+			Message: "Discussion {{.Discussion.Number}} ({{truncate .Discussion.Title 20}}) {{.Action}} in {{.Discussion.Category.Name}}" +
+				" on {{.Repository.FullName}} by {{.Sender.Login}}.",
+			URL: "{{.Discussion.HTMLURL}}",
+		},
+		"discussion:answered": {
+			Message: "Discussion {{.Discussion.Number}} ({{truncate .Discussion.Title 20}}) answered on {{.Repository.FullName}}" +
+				"{{with .Answer}} by {{.User.Login}}: {{truncate .Body 40}}{{else}} by {{.Sender.Login}}.{{end}}",
+			URL: "{{with .Answer}}{{.HTMLURL}}{{else}}{{.Discussion.HTMLURL}}{{end}}",
+		},
+		"discussion:category_changed": {
+			Message: "Discussion {{.Discussion.Number}} ({{truncate .Discussion.Title 20}}) moved" +
+				"{{with .Changes.Category}} from {{.From.Name}}{{end}} to {{.Discussion.Category.Name}} on {{.Repository.FullName}} by {{.Sender.Login}}.",
+			URL: "{{.Discussion.HTMLURL}}",
+		},
+		"discussion_comment": {
+			Message: "Comment {{.Action}} on discussion {{.Discussion.Number}} ({{truncate .Discussion.Title 20}}) on {{.Repository.FullName}} by {{.Sender.Login}}: {{truncate .Comment.Body 40}}",
+			URL:     "{{.Comment.HTMLURL}}",
+		},
+	})
+}
+
+// DiscussionCategory is the category a discussion is filed under.
+type DiscussionCategory struct {
+	Name         string `json:"name" required:"true"`
+	Slug         string `json:"slug"`
+	IsAnswerable bool   `json:"is_answerable"`
+}
+
+// Discussion is a GitHub Discussions thread.
+type Discussion struct {
+	Number   int                 `json:"number" required:"true"`
+	Title    string              `json:"title" required:"true"`
+	Body     string              `json:"body"`
+	HTMLURL  string              `json:"html_url" required:"true"`
+	State    string              `json:"state"`
+	Locked   bool                `json:"locked"`
+	Category *DiscussionCategory `json:"category" required:"true"`
+	User     *User               `json:"user"`
+	Labels   []Label             `json:"labels"`
+}
+
+// DiscussionEvent is the payload of discussion events.
+type DiscussionEvent struct {
+	Event
+	Discussion *Discussion `json:"discussion" required:"true"`
+
+	// Answer is the comment chosen as the answer, for answered discussions.
+	Answer *Comment `json:"answer"`
+
+	Changes struct {
+		Category *struct {
+			From DiscussionCategory `json:"from"`
+		} `json:"category"`
+	} `json:"changes"`
+}
+
+func (e *DiscussionEvent) describe(meta *Metadata) {
+	meta.Labels = labelNames(e.Discussion.Labels, nil)
+}
+
+// DiscussionCommentEvent is the payload of discussion_comment events.
+type DiscussionCommentEvent struct {
+	Event
+	Discussion *Discussion `json:"discussion" required:"true"`
+	Comment    *Comment    `json:"comment" required:"true"`
+}
+
+func (e *DiscussionCommentEvent) describe(meta *Metadata) {
+	meta.Labels = labelNames(e.Discussion.Labels, nil)
+}
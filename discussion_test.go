@@ -0,0 +1,67 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const discussionJSON = `{"action":"created","discussion":{"repository_url":"https://api.github.com/repos/octo-org/octo-repo","category":{"id":6,"node_id":"MDE4OkRpc2N1c3Npb25DYXRlZ29yeTY=","repository_id":17273051,"emoji":":pray:","name":"Q&A","description":"Ask the community for help","created_at":"2021-01-06T09:56:38.000-08:00","updated_at":"2021-01-06T09:56:38.000-08:00","slug":"q-a","is_answerable":true},"answer_html_url":null,"answer_chosen_at":null,"answer_chosen_by":null,"html_url":"https://github.com/octo-org/octo-repo/discussions/90","id":1011,"node_id":"MDEwOkRpc2N1c3Npb24xMDEx","number":90,"title":"How do I configure the retry limits?","user":{"login":"octocat","id":21031067,"node_id":"MDQ6VXNlcjIxMDMxMDY3","html_url":"https://github.com/octocat","type":"User","site_admin":false},"state":"open","locked":false,"comments":0,"created_at":"2021-03-03T02:43:49.000-08:00","updated_at":"2021-03-03T02:43:49.000-08:00","author_association":"OWNER","active_lock_reason":null,"body":"The docs don't say what the maximum backoff is."},"repository":{"id":17273051,"node_id":"MDEwOlJlcG9zaXRvcnkxNzI3MzA1MQ==","name":"octo-repo","full_name":"octo-org/octo-repo","private":true,"owner":{"login":"octo-org","id":6811672,"type":"Organization","site_admin":false},"html_url":"https://github.com/octo-org/octo-repo","description":"","fork":false,"url":"https://api.github.com/repos/octo-org/octo-repo","default_branch":"main"},"sender":{"login":"octocat","id":21031067,"node_id":"MDQ6VXNlcjIxMDMxMDY3","html_url":"https://github.com/octocat","type":"User","site_admin":false}}`
+
+const discussionAnsweredJSON = `{"action":"answered","discussion":{"repository_url":"https://api.github.com/repos/octo-org/octo-repo","category":{"id":6,"name":"Q&A","slug":"q-a","is_answerable":true},"answer_html_url":"https://github.com/octo-org/octo-repo/discussions/90#discussioncomment-544078","answer_chosen_at":"2021-03-03T02:45:20.000-08:00","answer_chosen_by":{"login":"octocat","id":21031067,"type":"User"},"html_url":"https://github.com/octo-org/octo-repo/discussions/90","id":1011,"number":90,"title":"How do I configure the retry limits?","user":{"login":"octocat","id":21031067,"type":"User"},"state":"open","locked":false,"comments":1,"body":"The docs don't say what the maximum backoff is."},"answer":{"id":544078,"node_id":"MDE3OkRpc2N1c3Npb25Db21tZW50NTQ0MDc4","html_url":"https://github.com/octo-org/octo-repo/discussions/90#discussioncomment-544078","parent_id":null,"child_comment_count":0,"repository_url":"octo-org/octo-repo","discussion_id":1011,"author_association":"MEMBER","user":{"login":"monalisa","id":2,"type":"User","site_admin":false},"created_at":"2021-03-03T02:44:43.000-08:00","updated_at":"2021-03-03T02:44:43.000-08:00","body":"It defaults to five minutes, see --retry-max."},"repository":{"id":17273051,"name":"octo-repo","full_name":"octo-org/octo-repo","private":true,"owner":{"login":"octo-org","id":6811672,"type":"Organization"},"html_url":"https://github.com/octo-org/octo-repo"},"sender":{"login":"octocat","id":21031067,"html_url":"https://github.com/octocat","type":"User","site_admin":false}}`
+
+const discussionCommentJSON = `{"action":"created","comment":{"id":544079,"node_id":"MDE3OkRpc2N1c3Npb25Db21tZW50NTQ0MDc5","html_url":"https://github.com/octo-org/octo-repo/discussions/90#discussioncomment-544079","parent_id":null,"child_comment_count":0,"repository_url":"octo-org/octo-repo","discussion_id":1011,"author_association":"MEMBER","user":{"login":"monalisa","id":2,"type":"User","site_admin":false},"created_at":"2021-03-03T02:49:05.000-08:00","updated_at":"2021-03-03T02:49:05.000-08:00","body":"Have you tried setting --retry-max on the command line?"},"discussion":{"repository_url":"https://api.github.com/repos/octo-org/octo-repo","category":{"id":6,"name":"Q&A","slug":"q-a","is_answerable":true},"html_url":"https://github.com/octo-org/octo-repo/discussions/90","id":1011,"number":90,"title":"How do I configure the retry limits?","user":{"login":"octocat","id":21031067,"type":"User"},"state":"open","locked":false,"comments":1,"body":"The docs don't say what the maximum backoff is."},"repository":{"id":17273051,"name":"octo-repo","full_name":"octo-org/octo-repo","private":true,"owner":{"login":"octo-org","id":6811672,"type":"Organization"},"html_url":"https://github.com/octo-org/octo-repo"},"sender":{"login":"monalisa","id":2,"html_url":"https://github.com/monalisa","type":"User","site_admin":false}}`
+
+func TestDiscussion(t *testing.T) {
+	name := "discussion"
+	note, _, err := parseEvent(name, discussionJSON)
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+
+	assert(t, note.Message == "Discussion 90 (How do I configure t...) created in Q&A on octo-org/octo-repo by octocat.", "Message incorrect")
+	assert(t, note.URL == "https://github.com/octo-org/octo-repo/discussions/90", "URL incorrect")
+
+	note, _, err = parseEvent(name, strings.Replace(discussionJSON, `"action":"created"`, `"action":"locked"`, 1))
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+	assert(t, note.Message == "Discussion 90 (How do I configure t...) locked in Q&A on octo-org/octo-repo by octocat.", "Locked message incorrect")
+}
+
+func TestDiscussionAnswered(t *testing.T) {
+	name := "discussion"
+	note, _, err := parseEvent(name, discussionAnsweredJSON)
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+
+	assert(t, note.Message == "Discussion 90 (How do I configure t...) answered on octo-org/octo-repo by monalisa: It defaults to five minutes, see --retry...", "Message incorrect")
+	assert(t, note.URL == "https://github.com/octo-org/octo-repo/discussions/90#discussioncomment-544078", "URL incorrect")
+}
+
+func TestDiscussionCategoryChanged(t *testing.T) {
+	name := "discussion"
+	payload := strings.Replace(discussionJSON, `"action":"created"`, `"action":"category_changed","changes":{"category":{"from":{"id":5,"name":"General","slug":"general","is_answerable":false}}}`, 1)
+	note, _, err := parseEvent(name, payload)
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+
+	assert(t, note.Message == "Discussion 90 (How do I configure t...) moved from General to Q&A on octo-org/octo-repo by octocat.", "Message incorrect")
+}
+
+func TestDiscussionComment(t *testing.T) {
+	name := "discussion_comment"
+	note, _, err := parseEvent(name, discussionCommentJSON)
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+
+	assert(t, note.Message == "Comment created on discussion 90 (How do I configure t...) on octo-org/octo-repo by monalisa: Have you tried setting --retry-max on th...", "Message incorrect")
+	assert(t, note.URL == "https://github.com/octo-org/octo-repo/discussions/90#discussioncomment-544079", "URL incorrect")
+}
@@ -0,0 +1,476 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// Fields tagged `required:"true"` must be present in the payload for the
+// notification to be built; see decodeEvent.
+
+// Repository is the repository an event happened in.
+type Repository struct {
+	FullName string `json:"full_name" required:"true"`
+	HTMLURL  string `json:"html_url"`
+}
+
+// User is an account referenced by an event, such as its sender.
+type User struct {
+	Login   string `json:"login" required:"true"`
+	HTMLURL string `json:"html_url"`
+	Type    string `json:"type"`
+}
+
+// Event holds the fields shared by every event payload.
+type Event struct {
+	Action     string      `json:"action"`
+	Repository *Repository `json:"repository" required:"true"`
+	Sender     *User       `json:"sender" required:"true"`
+}
+
+// common returns the shared fields of any event that embeds Event.
+func (e Event) common() Event {
+	return e
+}
+
+// eventPayload is implemented by every event type through Event.
+type eventPayload interface {
+	common() Event
+}
+
+// UnknownEvent is the payload of events without a handler.
+type UnknownEvent struct {
+	Event
+	Name string `json:"-"`
+}
+
+// Commit is a commit included in a push.
+type Commit struct {
+	ID       string   `json:"id"`
+	Message  string   `json:"message"`
+	URL      string   `json:"url"`
+	Distinct bool     `json:"distinct"`
+	Added    []string `json:"added"`
+	Removed  []string `json:"removed"`
+	Modified []string `json:"modified"`
+	Author   struct {
+		Name     string `json:"name"`
+		Email    string `json:"email"`
+		Username string `json:"username"`
+	} `json:"author"`
+}
+
+// Subject is the first line of the commit message.
+func (c *Commit) Subject() string {
+	if i := strings.Index(c.Message, "\n"); i >= 0 {
+		return strings.TrimSpace(c.Message[:i])
+	}
+	return c.Message
+}
+
+// AuthorName is the GitHub login of the author, or their name if the
+// commit isn't linked to an account.
+func (c *Commit) AuthorName() string {
+	if c.Author.Username != "" {
+		return c.Author.Username
+	}
+	return c.Author.Name
+}
+
+// Label is an issue or pull request label.
+type Label struct {
+	Name  string `json:"name"`
+	Color string `json:"color"`
+}
+
+// Branch is the head or base of a pull request.
+type Branch struct {
+	Ref   string `json:"ref"`
+	SHA   string `json:"sha"`
+	Label string `json:"label"`
+}
+
+// PullRequest is the pull request referenced by pull request events.
+type PullRequest struct {
+	Number  int     `json:"number" required:"true"`
+	Title   string  `json:"title" required:"true"`
+	Body    string  `json:"body"`
+	HTMLURL string  `json:"html_url" required:"true"`
+	State   string  `json:"state"`
+	User    *User   `json:"user"`
+	Labels  []Label `json:"labels"`
+	Head    *Branch `json:"head"`
+	Base    *Branch `json:"base"`
+
+	Draft     bool  `json:"draft"`
+	Merged    bool  `json:"merged"`
+	MergedBy  *User `json:"merged_by"`
+	AutoMerge *struct {
+		EnabledBy   *User  `json:"enabled_by"`
+		MergeMethod string `json:"merge_method"`
+	} `json:"auto_merge"`
+}
+
+// Issue is the issue referenced by issue events.
+type Issue struct {
+	Number  int     `json:"number" required:"true"`
+	Title   string  `json:"title" required:"true"`
+	Body    string  `json:"body"`
+	HTMLURL string  `json:"html_url" required:"true"`
+	State   string  `json:"state"`
+	User    *User   `json:"user"`
+	Labels  []Label `json:"labels"`
+
+	Assignees   []User     `json:"assignees"`
+	Milestone   *Milestone `json:"milestone"`
+	StateReason string     `json:"state_reason"`
+}
+
+// Reason is why the issue was closed, as words.
+func (i *Issue) Reason() string {
+	return strings.Replace(i.StateReason, "_", " ", -1)
+}
+
+// Comment is a comment on an issue, pull request, commit or diff. Comments
+// on a diff or commit can have a path and line.
+type Comment struct {
+	ID       int    `json:"id"`
+	Body     string `json:"body"`
+	HTMLURL  string `json:"html_url" required:"true"`
+	User     *User  `json:"user"`
+	CommitID string `json:"commit_id"`
+	Path     string `json:"path"`
+	Line     int    `json:"line"`
+}
+
+// Review is a pull request review.
+type Review struct {
+	ID      int    `json:"id"`
+	State   string `json:"state" required:"true"`
+	Body    string `json:"body"`
+	HTMLURL string `json:"html_url" required:"true"`
+	User    *User  `json:"user"`
+}
+
+// RefEvent is the payload of create and delete events.
+type RefEvent struct {
+	Event
+	Ref     string `json:"ref" required:"true"`
+	RefType string `json:"ref_type" required:"true"`
+}
+
+// WatchEvent is the payload of watch (star) events.
+type WatchEvent struct {
+	Event
+}
+
+// ForkEvent is the payload of fork events.
+type ForkEvent struct {
+	Event
+	Forkee *Repository `json:"forkee" required:"true"`
+}
+
+// PushEvent is the payload of push events.
+type PushEvent struct {
+	Event
+	Ref     string   `json:"ref" required:"true"`
+	Before  string   `json:"before"`
+	After   string   `json:"after"`
+	Compare string   `json:"compare" required:"true"`
+	Created bool     `json:"created"`
+	Deleted bool     `json:"deleted"`
+	Forced  bool     `json:"forced"`
+	Commits []Commit `json:"commits" required:"true"`
+
+	HeadCommit *Commit `json:"head_commit"`
+
+	// commitLimit is the most commits to list one per line.
+	commitLimit int
+}
+
+// Tag reports whether a tag was pushed rather than a branch.
+func (e *PushEvent) Tag() bool {
+	return strings.HasPrefix(e.Ref, "refs/tags/")
+}
+
+// RefName is the name of the branch or tag pushed.
+func (e *PushEvent) RefName() string {
+	return strings.TrimPrefix(branch(e.Ref), "refs/tags/")
+}
+
+// Authors lists the distinct authors of the pushed commits, or nothing if
+// they were all written by the pusher.
+func (e *PushEvent) Authors() []string {
+	var authors []string
+	seen := make(map[string]bool)
+	for i := range e.Commits {
+		author := e.Commits[i].AuthorName()
+		if author != "" && !seen[author] {
+			seen[author] = true
+			authors = append(authors, author)
+		}
+	}
+	if len(authors) == 1 && authors[0] == e.Sender.Login {
+		return nil
+	}
+	return authors
+}
+
+// Paths lists the files added, modified or removed by the pushed commits.
+func (e *PushEvent) Paths() []string {
+	var paths []string
+	seen := make(map[string]bool)
+	for _, commit := range e.Commits {
+		for _, files := range [][]string{commit.Added, commit.Modified, commit.Removed} {
+			for _, path := range files {
+				if !seen[path] {
+					seen[path] = true
+					paths = append(paths, path)
+				}
+			}
+		}
+	}
+	return paths
+}
+
+// CommitLines summarizes each commit as its short SHA and subject, up to the
+// configured limit. Pushes of a single commit need no summary beyond the
+// head commit.
+func (e *PushEvent) CommitLines() []string {
+	if len(e.Commits) < 2 || e.commitLimit <= 0 {
+		return nil
+	}
+	var lines []string
+	for i := range e.Commits {
+		if i == e.commitLimit {
+			lines = append(lines, fmt.Sprintf("+%d more", len(e.Commits)-i))
+			break
+		}
+		commit := &e.Commits[i]
+		lines = append(lines, shortSHA(commit.ID)+" "+truncate(commit.Subject(), 50))
+	}
+	return lines
+}
+
+// PullRequestEvent is the payload of pull_request events.
+type PullRequestEvent struct {
+	Event
+	Number      int          `json:"number"`
+	PullRequest *PullRequest `json:"pull_request" required:"true"`
+	Label       *Label       `json:"label"`
+
+	// RequestedReviewer or RequestedTeam is set for review_requested and
+	// review_request_removed.
+	RequestedReviewer *User `json:"requested_reviewer"`
+	RequestedTeam     *Team `json:"requested_team"`
+
+	// Assignee is the user assigned or unassigned.
+	Assignee *User `json:"assignee"`
+
+	// Before and After are the old and new head commits for synchronize.
+	Before string `json:"before"`
+	After  string `json:"after"`
+
+	// Reason is why auto-merge was disabled.
+	Reason string `json:"reason"`
+}
+
+// Reviewer is the user or team a review request is about.
+func (e *PullRequestEvent) Reviewer() string {
+	if e.RequestedReviewer != nil {
+		return e.RequestedReviewer.Login
+	}
+	if e.RequestedTeam != nil {
+		return e.RequestedTeam.Name
+	}
+	return ""
+}
+
+// PullRequestReviewEvent is the payload of pull_request_review events.
+type PullRequestReviewEvent struct {
+	Event
+	Review      *Review      `json:"review" required:"true"`
+	PullRequest *PullRequest `json:"pull_request" required:"true"`
+}
+
+// PullRequestReviewCommentEvent is the payload of
+// pull_request_review_comment events.
+type PullRequestReviewCommentEvent struct {
+	Event
+	Comment     *Comment     `json:"comment" required:"true"`
+	PullRequest *PullRequest `json:"pull_request" required:"true"`
+}
+
+// IssuesEvent is the payload of issues events.
+type IssuesEvent struct {
+	Event
+	Issue *Issue `json:"issue" required:"true"`
+	Label *Label `json:"label"`
+
+	// Assignee is the user assigned or unassigned.
+	Assignee *User `json:"assignee"`
+
+	// Milestone is the milestone added or removed.
+	Milestone *Milestone `json:"milestone"`
+
+	Changes struct {
+		NewIssue      *Issue      `json:"new_issue"`
+		NewRepository *Repository `json:"new_repository"`
+	} `json:"changes"`
+}
+
+// assignedTo reports whether the issue is assigned to any of logins, or
+// the event is about assigning or unassigning one of them.
+func (e *IssuesEvent) assignedTo(logins map[string]bool) bool {
+	if e.Assignee != nil && logins[e.Assignee.Login] {
+		return true
+	}
+	for _, assignee := range e.Issue.Assignees {
+		if logins[assignee.Login] {
+			return true
+		}
+	}
+	return false
+}
+
+// IssueCommentEvent is the payload of issue_comment events.
+type IssueCommentEvent struct {
+	Event
+	Issue   *Issue   `json:"issue" required:"true"`
+	Comment *Comment `json:"comment" required:"true"`
+}
+
+// CommitCommentEvent is the payload of commit_comment events.
+type CommitCommentEvent struct {
+	Event
+	Comment *Comment `json:"comment" required:"true"`
+}
+
+// PingEvent is sent when a webhook is first configured.
+type PingEvent struct {
+	Event
+	Zen    string `json:"zen" required:"true"`
+	HookID int    `json:"hook_id"`
+}
+
+// describer is implemented by events that can add to the metadata built
+// from their common fields.
+type describer interface {
+	describe(meta *Metadata)
+}
+
+func (e *RefEvent) describe(meta *Metadata) {
+	meta.Ref = branch(e.Ref)
+}
+
+func (e *PushEvent) describe(meta *Metadata) {
+	meta.Ref = e.RefName()
+	meta.Paths = e.Paths()
+}
+
+func (e *PullRequestEvent) describe(meta *Metadata) {
+	if e.PullRequest.Base != nil {
+		meta.Ref = e.PullRequest.Base.Ref
+	}
+	meta.Labels = labelNames(e.PullRequest.Labels, e.Label)
+	if e.Action == "closed" && e.PullRequest.Merged {
+		meta.State = "merged"
+	}
+}
+
+func (e *IssuesEvent) describe(meta *Metadata) {
+	meta.Labels = labelNames(e.Issue.Labels, e.Label)
+	if e.Action == "closed" {
+		meta.State = e.Issue.StateReason
+	}
+}
+
+func (e *IssueCommentEvent) describe(meta *Metadata) {
+	meta.Labels = labelNames(e.Issue.Labels, nil)
+}
+
+func (e *PullRequestReviewEvent) describe(meta *Metadata) {
+	meta.State = e.Review.State
+	if e.PullRequest.Base != nil {
+		meta.Ref = e.PullRequest.Base.Ref
+	}
+	meta.Labels = labelNames(e.PullRequest.Labels, nil)
+}
+
+func (e *PullRequestReviewCommentEvent) describe(meta *Metadata) {
+	if e.PullRequest.Base != nil {
+		meta.Ref = e.PullRequest.Base.Ref
+	}
+	meta.Labels = labelNames(e.PullRequest.Labels, nil)
+}
+
+// labelNames lists the names of labels, including the label an event was
+// about if it isn't among them (as when it was just removed).
+func labelNames(labels []Label, changed *Label) []string {
+	var names []string
+	for _, label := range labels {
+		names = append(names, label.Name)
+	}
+	if changed != nil {
+		for _, name := range names {
+			if name == changed.Name {
+				return names
+			}
+		}
+		names = append(names, changed.Name)
+	}
+	return names
+}
+
+// decodeEvent unmarshals payload into event and checks that every required
+// field was present, naming the first one that wasn't.
+func decodeEvent(payload []byte, event interface{}) error {
+	if err := json.Unmarshal(payload, event); err != nil {
+		return err
+	}
+	return checkRequired(reflect.ValueOf(event), "")
+}
+
+func checkRequired(v reflect.Value, path string) error {
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return checkRequired(v.Elem(), path)
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			value := v.Field(i)
+
+			name := path
+			if !field.Anonymous {
+				name = strings.Split(field.Tag.Get("json"), ",")[0]
+				if path != "" {
+					name = path + "." + name
+				}
+			}
+
+			if field.Tag.Get("required") == "true" && isZero(value) {
+				return fmt.Errorf("Unable to get %s: missing from payload", name)
+			}
+			if err := checkRequired(value, name); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if err := checkRequired(v.Index(i), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func isZero(v reflect.Value) bool {
+	return reflect.DeepEqual(v.Interface(), reflect.Zero(v.Type()).Interface())
+}
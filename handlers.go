@@ -0,0 +1,74 @@
+package main
+
+import (
+	"github.com/Sirupsen/logrus"
+	"github.com/justone/pmb-gh/webhook"
+	"github.com/justone/pmb/api"
+)
+
+// Metadata, EventHandler and EventHandlerFunc are defined in package
+// webhook, so that handlers can also be written in other packages.
+type (
+	Metadata         = webhook.Metadata
+	EventHandler     = webhook.EventHandler
+	EventHandlerFunc = webhook.EventHandlerFunc
+)
+
+func newMetadata(event Event) *Metadata {
+	meta := &Metadata{Action: event.Action}
+	if event.Repository != nil {
+		meta.Repository = event.Repository.FullName
+	}
+	if event.Sender != nil {
+		meta.Sender = event.Sender.Login
+		meta.SenderType = event.Sender.Type
+	}
+	return meta
+}
+
+// defaultHandler is used for events without a registered handler.
+var defaultHandler EventHandler = EventHandlerFunc(handleUnknown)
+
+// RegisterHandler sets the handler for the named event, replacing any
+// handler already registered for it. Handlers are usually registered from
+// init functions, one file per group of events; other packages register
+// theirs with webhook.Register.
+func RegisterHandler(name string, handler EventHandler) {
+	webhook.Register(name, handler)
+}
+
+func lookupHandler(name string) EventHandler {
+	if handler, ok := webhook.Lookup(name); ok {
+		return handler
+	}
+	return defaultHandler
+}
+
+// parseEvent dispatches a delivery to the handler registered for its event.
+func parseEvent(name string, json string) (*pmb.Notification, *Metadata, error) {
+	note, meta, err := lookupHandler(name).Handle(name, []byte(json))
+	if err != nil {
+		return nil, nil, err
+	}
+	if meta == nil {
+		meta = &Metadata{}
+	}
+	meta.Event = name
+
+	if note != nil {
+		logrus.Debugf("message: %s", note.Message)
+		logrus.Debugf("url: %s", note.URL)
+	}
+	return note, meta, nil
+}
+
+func handleUnknown(name string, payload []byte) (*pmb.Notification, *Metadata, error) {
+	event := UnknownEvent{Name: name}
+	if err := decodeEvent(payload, &event); err != nil {
+		return nil, nil, err
+	}
+	meta := newMetadata(event.Event)
+	meta.Data = &event
+	note, err := renderNotification(name, meta.Action, &event)
+	return note, meta, err
+}
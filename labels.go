@@ -0,0 +1,67 @@
+package main
+
+import "time"
+
+func init() {
+	RegisterHandler("label", payloadHandler(func() eventPayload { return &LabelEvent{} }))
+	RegisterHandler("milestone", payloadHandler(func() eventPayload { return &MilestoneEvent{} }))
+	registerTemplates(map[string]messageTemplate{
+		"label": {
+			Message: "Label {{.Label.Name}} {{.Action}} on {{.Repository.FullName}} by {{.Sender.Login}}.",
+			URL:     "{{.Repository.HTMLURL}}/labels",
+		},
+		"label:edited": {
+			Message: "Label {{.Label.Name}} edited{{with .Changes.Name}} (was {{.From}}){{end}} on {{.Repository.FullName}} by {{.Sender.Login}}.",
+			URL:     "{{.Repository.HTMLURL}}/labels",
+		},
+		"milestone": {
+			Message: "Milestone {{.Milestone.Title}} {{.Action}} on {{.Repository.FullName}} by {{.Sender.Login}}" +
+				"{{with .Milestone.DueOn}}, due {{.Format \"Jan 2, 2006\"}}{{end}}.",
+			URL: "{{.Milestone.HTMLURL}}",
+		},
+		"milestone:closed": {
+			Message: "Milestone {{.Milestone.Title}} closed on {{.Repository.FullName}} by {{.Sender.Login}}" +
+				" with {{.Milestone.ClosedIssues}} closed and {{.Milestone.OpenIssues}} open issue(s)" +
+				"{{with .Milestone.DueOn}}, due {{.Format \"Jan 2, 2006\"}}{{end}}.",
+			URL: "{{.Milestone.HTMLURL}}",
+		},
+	})
+}
+
+// LabelEvent is the payload of label events, sent when a repository's labels
+// change.
+type LabelEvent struct {
+	Event
+	Label   *Label `json:"label" required:"true"`
+	Changes struct {
+		Name *struct {
+			From string `json:"from"`
+		} `json:"name"`
+	} `json:"changes"`
+}
+
+func (e *LabelEvent) describe(meta *Metadata) {
+	meta.Labels = []string{e.Label.Name}
+}
+
+// Milestone is a milestone that issues and pull requests can be assigned to.
+type Milestone struct {
+	Number       int        `json:"number"`
+	Title        string     `json:"title" required:"true"`
+	Description  string     `json:"description"`
+	State        string     `json:"state"`
+	HTMLURL      string     `json:"html_url"`
+	OpenIssues   int        `json:"open_issues"`
+	ClosedIssues int        `json:"closed_issues"`
+	DueOn        *time.Time `json:"due_on"`
+}
+
+// MilestoneEvent is the payload of milestone events.
+type MilestoneEvent struct {
+	Event
+	Milestone *Milestone `json:"milestone" required:"true"`
+}
+
+func (e *MilestoneEvent) describe(meta *Metadata) {
+	meta.State = e.Milestone.State
+}
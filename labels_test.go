@@ -0,0 +1,43 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const milestoneJSON = `{"action":"created","milestone":{"url":"https://api.github.com/repos/Codertocat/Hello-World/milestones/1","html_url":"https://github.com/Codertocat/Hello-World/milestone/1","labels_url":"https://api.github.com/repos/Codertocat/Hello-World/milestones/1/labels","id":4317517,"node_id":"MDk6TWlsZXN0b25lNDMxNzUxNw==","number":1,"title":"v1.0","description":"Add new space flight simulator","creator":{"login":"Codertocat","id":21031067,"type":"User","site_admin":false},"open_issues":2,"closed_issues":5,"state":"open","created_at":"2019-05-15T15:20:17Z","updated_at":"2019-05-15T15:20:17Z","due_on":"2019-05-31T07:00:00Z","closed_at":null},"repository":{"id":186853002,"name":"Hello-World","full_name":"Codertocat/Hello-World","private":false,"owner":{"login":"Codertocat","id":21031067,"type":"User"},"html_url":"https://github.com/Codertocat/Hello-World"},"sender":{"login":"Codertocat","id":21031067,"html_url":"https://github.com/Codertocat","type":"User","site_admin":false}}`
+
+func TestLabel(t *testing.T) {
+	name := "label"
+	json := `{"action":"edited","label":{"id":1362934389,"node_id":"MDU6TGFiZWwxMzYyOTM0Mzg5","url":"https://api.github.com/repos/Codertocat/Hello-World/labels/bugfix","name":"bugfix","color":"000000","default":false},"changes":{"name":{"from":"bug"}},"repository":{"id":186853002,"name":"Hello-World","full_name":"Codertocat/Hello-World","html_url":"https://github.com/Codertocat/Hello-World"},"sender":{"login":"Codertocat","id":21031067,"type":"User"}}`
+	note, meta, err := parseEvent(name, json)
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+
+	assert(t, note.Message == "Label bugfix edited (was bug) on Codertocat/Hello-World by Codertocat.", "Message incorrect")
+	assert(t, note.URL == "https://github.com/Codertocat/Hello-World/labels", "URL incorrect")
+	assert(t, len(meta.Labels) == 1 && meta.Labels[0] == "bugfix", "Metadata incorrect")
+}
+
+func TestMilestone(t *testing.T) {
+	name := "milestone"
+	note, _, err := parseEvent(name, milestoneJSON)
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+
+	assert(t, note.Message == "Milestone v1.0 created on Codertocat/Hello-World by Codertocat, due May 31, 2019.", "Message incorrect")
+	assert(t, note.URL == "https://github.com/Codertocat/Hello-World/milestone/1", "URL incorrect")
+
+	closed := strings.Replace(milestoneJSON, `"action":"created"`, `"action":"closed"`, 1)
+	closed = strings.Replace(closed, `"due_on":"2019-05-31T07:00:00Z"`, `"due_on":null`, 1)
+	note, _, err = parseEvent(name, closed)
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+	assert(t, note.Message == "Milestone v1.0 closed on Codertocat/Hello-World by Codertocat with 5 closed and 2 open issue(s).", "Closed message incorrect")
+}
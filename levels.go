@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// levelMapping sets the level of notifications for an event, optionally
+// narrowed to an action (or review state) and a repository glob. Mappings
+// are written as "event[:action][@repository]=level", with "*" matching any
+// event.
+type levelMapping struct {
+	event      string
+	action     string
+	repository *regexp.Regexp
+	level      float64
+}
+
+// specificity ranks mappings so that a repository match beats an action
+// match, which beats a plain event match.
+func (m levelMapping) specificity() int {
+	score := 0
+	if m.event != "*" {
+		score++
+	}
+	if m.action != "" {
+		score += 2
+	}
+	if m.repository != nil {
+		score += 4
+	}
+	return score
+}
+
+func (m levelMapping) matches(meta *Metadata) bool {
+	if m.event != "*" && m.event != meta.Event {
+		return false
+	}
+	if m.action != "" && m.action != meta.Action && m.action != meta.State {
+		return false
+	}
+	if m.repository != nil && !m.repository.MatchString(meta.Repository) {
+		return false
+	}
+	return true
+}
+
+func parseLevelKey(key string, level float64) (levelMapping, error) {
+	m := levelMapping{event: key, level: level}
+	if level < 0 {
+		return m, fmt.Errorf("Invalid level %g for %s", level, key)
+	}
+
+	if i := strings.Index(m.event, "@"); i >= 0 {
+		repository, err := compileGlob(m.event[i+1:])
+		if err != nil || i == len(m.event)-1 {
+			return m, fmt.Errorf("Invalid repository in level mapping %s", key)
+		}
+		m.repository = repository
+		m.event = m.event[:i]
+	}
+	if i := strings.Index(m.event, ":"); i >= 0 {
+		m.action = m.event[i+1:]
+		m.event = m.event[:i]
+	}
+	if m.event == "" {
+		return m, fmt.Errorf("Missing event in level mapping %s", key)
+	}
+	return m, nil
+}
+
+// parseLevelMapping parses a mapping given on the command line.
+func parseLevelMapping(spec string) (levelMapping, error) {
+	i := strings.LastIndex(spec, "=")
+	if i < 0 {
+		return levelMapping{}, fmt.Errorf("Invalid level mapping %s, expected key=level", spec)
+	}
+	level, err := strconv.ParseFloat(spec[i+1:], 64)
+	if err != nil {
+		return levelMapping{}, fmt.Errorf("Invalid level in mapping %s: %s", spec, err)
+	}
+	return parseLevelKey(spec[:i], level)
+}
+
+// resolveLevel returns the level of the most specific mapping matching the
+// delivery, or fallback if none do. Among equally specific mappings the
+// last one wins.
+func resolveLevel(mappings []levelMapping, meta *Metadata, fallback float64) float64 {
+	level := fallback
+	best := -1
+	for _, m := range mappings {
+		if !m.matches(meta) {
+			continue
+		}
+		if score := m.specificity(); score >= best {
+			best = score
+			level = m.level
+		}
+	}
+	return level
+}
+
+// environmentLevel sets the level of deployment notifications for an
+// environment glob, optionally narrowed to a deployment state. Environment
+// levels are written as "environment[:state]=level".
+type environmentLevel struct {
+	environment *regexp.Regexp
+	state       string
+	level       float64
+}
+
+func parseEnvironmentKey(key string, level float64) (environmentLevel, error) {
+	e := environmentLevel{level: level}
+	if level < 0 {
+		return e, fmt.Errorf("Invalid level %g for %s", level, key)
+	}
+
+	pattern := key
+	if i := strings.Index(pattern, ":"); i >= 0 {
+		e.state = pattern[i+1:]
+		pattern = pattern[:i]
+	}
+	if pattern == "" {
+		return e, fmt.Errorf("Missing environment in level mapping %s", key)
+	}
+	environment, err := compileGlob(pattern)
+	if err != nil {
+		return e, fmt.Errorf("Invalid environment in level mapping %s", key)
+	}
+	e.environment = environment
+	return e, nil
+}
+
+// parseEnvironmentLevel parses an environment level given on the command
+// line.
+func parseEnvironmentLevel(spec string) (environmentLevel, error) {
+	i := strings.LastIndex(spec, "=")
+	if i < 0 {
+		return environmentLevel{}, fmt.Errorf("Invalid environment level %s, expected environment=level", spec)
+	}
+	level, err := strconv.ParseFloat(spec[i+1:], 64)
+	if err != nil {
+		return environmentLevel{}, fmt.Errorf("Invalid level in mapping %s: %s", spec, err)
+	}
+	return parseEnvironmentKey(spec[:i], level)
+}
+
+// resolveEnvironmentLevel returns the level for the environment of a
+// deployment, preferring levels for its state. It reports false when the
+// delivery has no environment or none of the levels match.
+func resolveEnvironmentLevel(levels []environmentLevel, meta *Metadata) (float64, bool) {
+	if meta.Environment == "" {
+		return 0, false
+	}
+	var level float64
+	found, stateFound := false, false
+	for _, e := range levels {
+		if !e.environment.MatchString(meta.Environment) {
+			continue
+		}
+		if e.state != "" && e.state != meta.State {
+			continue
+		}
+		if stateFound && e.state == "" {
+			continue
+		}
+		level, found = e.level, true
+		stateFound = e.state != ""
+	}
+	return level, found
+}
+
+// parseSeverityLevel parses a level for a security alert severity given on
+// the command line as "severity=level".
+func parseSeverityLevel(spec string) (string, float64, error) {
+	i := strings.LastIndex(spec, "=")
+	if i < 0 {
+		return "", 0, fmt.Errorf("Invalid severity level %s, expected severity=level", spec)
+	}
+	severity := spec[:i]
+	if _, ok := defaultSeverityLevels[severity]; !ok {
+		return "", 0, fmt.Errorf("Unknown severity %s, expected low, medium, high or critical", severity)
+	}
+	level, err := strconv.ParseFloat(spec[i+1:], 64)
+	if err != nil || level < 0 {
+		return "", 0, fmt.Errorf("Invalid level in severity level %s", spec)
+	}
+	return severity, level, nil
+}
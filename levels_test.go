@@ -0,0 +1,62 @@
+package main
+
+import "testing"
+
+func testLevels(t *testing.T, specs ...string) []levelMapping {
+	var mappings []levelMapping
+	for _, spec := range specs {
+		m, err := parseLevelMapping(spec)
+		if err != nil {
+			t.Fatalf("Error: %s", err)
+		}
+		mappings = append(mappings, m)
+	}
+	return mappings
+}
+
+func TestResolveLevel(t *testing.T) {
+	levels := testLevels(t,
+		"watch=2",
+		"pull_request_review=5",
+		"pull_request_review:changes_requested=6",
+		"*@org/toy-*=1",
+		"pull_request_review:changes_requested@org/production=8",
+	)
+
+	assert(t, resolveLevel(levels, &Metadata{Event: "watch", Repository: "org/website"}, 4) == 2, "event level incorrect")
+	assert(t, resolveLevel(levels, &Metadata{Event: "push", Repository: "org/website"}, 4) == 4, "default level incorrect")
+	assert(t, resolveLevel(levels, &Metadata{Event: "pull_request_review", Action: "submitted", State: "approved", Repository: "org/website"}, 4) == 5, "review level incorrect")
+	assert(t, resolveLevel(levels, &Metadata{Event: "pull_request_review", Action: "submitted", State: "changes_requested", Repository: "org/website"}, 4) == 6, "review state level incorrect")
+	assert(t, resolveLevel(levels, &Metadata{Event: "watch", Repository: "org/toy-box"}, 4) == 1, "repository level incorrect")
+	assert(t, resolveLevel(levels, &Metadata{Event: "pull_request_review", Action: "submitted", State: "changes_requested", Repository: "org/production"}, 4) == 8, "most specific level incorrect")
+}
+
+func TestParseLevelMappingInvalid(t *testing.T) {
+	for _, spec := range []string{"watch", "watch=loud", "=2", ":opened=2", "watch@=2", "watch=-1"} {
+		_, err := parseLevelMapping(spec)
+
+		assert(t, err != nil, "invalid level mapping accepted: "+spec)
+	}
+}
+
+func TestResolveEnvironmentLevel(t *testing.T) {
+	var levels []environmentLevel
+	for _, spec := range []string{"production:failure=8", "production=6", "review-*=2"} {
+		e, err := parseEnvironmentLevel(spec)
+		if err != nil {
+			t.Fatalf("Error: %s", err)
+		}
+		levels = append(levels, e)
+	}
+
+	level, ok := resolveEnvironmentLevel(levels, &Metadata{Event: "deployment_status", Environment: "production", State: "failure"})
+	assert(t, ok && level == 8, "state level incorrect")
+	level, ok = resolveEnvironmentLevel(levels, &Metadata{Event: "deployment_status", Environment: "production", State: "success"})
+	assert(t, ok && level == 6, "environment level incorrect")
+	level, ok = resolveEnvironmentLevel(levels, &Metadata{Event: "deployment", Environment: "review-1234"})
+	assert(t, ok && level == 2, "environment glob level incorrect")
+	_, ok = resolveEnvironmentLevel(levels, &Metadata{Event: "deployment", Environment: "staging"})
+	assert(t, !ok, "unknown environment matched")
+	_, ok = resolveEnvironmentLevel(levels, &Metadata{Event: "push"})
+	assert(t, !ok, "event without environment matched")
+}
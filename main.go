@@ -2,10 +2,9 @@ package main
 
 import (
 	"fmt"
-	"io/ioutil"
 	"net/http"
 	"os"
-	"strings"
+	"time"
 
 	"github.com/Sirupsen/logrus"
 	"github.com/jessevdk/go-flags"
@@ -17,8 +16,45 @@ var opts struct {
 	Primary string   `short:"m" long:"pmb-uri" description:"Primary PMB URI."`
 	Ignore  []string `short:"i" long:"ignore" description:"Github username to ignore."`
 	Level   float64  `short:"l" long:"level" description:"Level at which to send notifications." default:"4"`
+	Levels  []string `short:"L" long:"event-level" description:"Level for an event, as \"event[:action][@repository]=level\"."`
 	Host    string   `short:"h" long:"host" description:"Host to listen on." default:"0.0.0.0"`
 	Port    string   `short:"p" long:"port" description:"Port to listen on." default:"3000"`
+
+	Secrets    []string `short:"s" long:"secret" description:"Secret used to verify webhook signatures, as \"<secret> [label] [expiry]\"." env:"PMB_GH_SECRET" env-delim:","`
+	SecretFile string   `long:"secret-file" description:"File containing webhook secrets, one per line."`
+
+	DedupeTTL  time.Duration `long:"dedupe-ttl" description:"How long to remember delivery IDs for." default:"24h"`
+	DedupeSize int           `long:"dedupe-size" description:"Maximum number of delivery IDs to remember." default:"10000"`
+	DedupeFile string        `long:"dedupe-file" description:"File to persist delivery IDs in across restarts."`
+
+	Templates string `short:"t" long:"templates" description:"YAML file of message templates per event and action."`
+
+	WorkflowActions     []string `long:"workflow-action" description:"Workflow run and job action to notify about, * for all (default: completed)."`
+	WorkflowConclusions []string `long:"workflow-conclusion" description:"Conclusion of completed workflow runs and jobs to notify about, * for all (default: any but success, neutral and skipped)."`
+
+	IssueAssignees []string `long:"issue-assignee" description:"Only notify about issues assigned to this login."`
+	PushCommits    int      `long:"push-commits" description:"Most commits of a push to list one per line, 0 for none." default:"5"`
+
+	EnvironmentLevels []string `short:"E" long:"environment-level" description:"Level for deployments to an environment, as \"environment[:state]=level\"."`
+	SeverityLevels    []string `long:"severity-level" description:"Level for security alerts of a severity, as \"severity=level\" (default: low=5, medium=6, high=7, critical=8)."`
+
+	Mentions     []string `long:"mention" description:"Login or org/team handle whose mentions, review requests and assignments are escalated."`
+	MentionLevel float64  `long:"mention-level" description:"Level for notifications that mention one of --mention." default:"6"`
+
+	Bots      string   `long:"bots" description:"What to do with deliveries sent by bots: ignore, lower (send at --bot-level) or notify." default:"notify"`
+	BotLevel  float64  `long:"bot-level" description:"Level for deliveries sent by bots with --bots=lower." default:"2"`
+	BotAllow  []string `long:"bot-allow" description:"Bot login to always notify about, with or without the [bot] suffix."`
+	BotEvents []string `long:"bot-event" description:"Bot mode for an event, as \"event[:action]=mode\"."`
+
+	StatusSettle time.Duration `long:"status-settle" description:"Collapse commit statuses into one notification per commit, sent once none has changed for this long. 0 sends every status."`
+
+	Outbox      string        `long:"outbox" description:"File to queue notifications in until PMB has received them, so that they survive a restart. An empty value keeps the queue in memory only, losing it on restart." default:"pmb-gh.outbox"`
+	RetryMin    time.Duration `long:"retry-min" description:"Initial delay before resending a notification or reconnecting to PMB." default:"1s"`
+	RetryMax    time.Duration `long:"retry-max" description:"Maximum delay before resending a notification or reconnecting to PMB." default:"5m"`
+	SendTimeout time.Duration `long:"send-timeout" description:"How long to wait for PMB to accept a notification before reconnecting." default:"30s"`
+
+	Config     string        `short:"c" long:"config" description:"YAML config file, reloaded on SIGHUP or when it changes."`
+	ConfigPoll time.Duration `long:"config-poll" description:"How often to check the config file for changes, 0 to disable." default:"5s"`
 }
 
 func main() {
@@ -28,14 +64,6 @@ func main() {
 		os.Exit(1)
 	}
 
-	ignoreUsers := make(map[string]bool)
-	for _, u := range opts.Ignore {
-		ignoreUsers[u] = true
-	}
-
-	bus := pmb.GetPMB(opts.Primary)
-	id := pmb.GenerateRandomID("github")
-
 	if opts.Verbose {
 		logrus.SetLevel(logrus.DebugLevel)
 	} else {
@@ -43,59 +71,44 @@ func main() {
 	}
 	logrus.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
 
-	conn, err := bus.ConnectClient(id, false)
+	config, err := loadSettings(opts.Config)
+	if err != nil {
+		logrus.Warnf("%s", err)
+		os.Exit(1)
+	}
+	applySettings(config)
+	go watchConfig(opts.Config, opts.ConfigPoll)
+
+	deliveries, err := newDeliveryCache(opts.DedupeTTL, opts.DedupeSize, opts.DedupeFile)
 	if err != nil {
-		logrus.Warnf("Error connecting to PMB: %s", err)
+		logrus.Warnf("%s", err)
 		os.Exit(1)
 	}
+	defer deliveries.Close()
+
+	conn := newSupervisor(pmb.GetPMB(opts.Primary), opts.SendTimeout, opts.RetryMin, opts.RetryMax)
+
+	queue, err := openOutbox(opts.Outbox, conn.Send, opts.RetryMin, opts.RetryMax)
+	if err != nil {
+		logrus.Warnf("%s", err)
+		os.Exit(1)
+	}
+	defer queue.Close()
+	conn.connected = queue.Retry
+	go conn.Run(nil)
+	go queue.Run(nil)
+
+	srv := &server{deliveries: deliveries, outbox: queue}
+	if opts.StatusSettle > 0 {
+		statuses = newStatusAggregator(opts.StatusSettle, func(note *pmb.Notification, meta *Metadata) {
+			if err := srv.deliver(note, meta); err != nil {
+				logrus.Warnf("%s", err)
+			}
+		})
+	}
 
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		var ip string
-		if realIP, ok := r.Header["X-Real-Ip"]; ok {
-			ip = realIP[0]
-		} else {
-			ip = r.RemoteAddr
-		}
-		logrus.Infof(strings.Join([]string{r.RequestURI, ip, r.Method, fmt.Sprintf("%s", r.Header)}, " "))
-
-		body, err := ioutil.ReadAll(r.Body)
-		if err != nil {
-			http.Error(w, "Unable to read request body: "+err.Error(), http.StatusInternalServerError)
-			return
-		}
-		var eventName string
-		if eventHeaders, ok := r.Header["X-Github-Event"]; ok {
-			eventName = eventHeaders[0]
-		} else {
-			logrus.Warnf("Github event name not found")
-			return
-		}
-
-		eventJSON := string(body)
-
-		notification, login, err := parseEvent(eventName, eventJSON)
-
-		if _, ok := ignoreUsers[login]; ok {
-			logrus.Warnf(fmt.Sprintf("ignoring notification from %s", login))
-			return
-		}
-		if err != nil {
-			logrus.Warnf("Unable to parse event %s: %s, body: %s", eventName, err, eventJSON)
-			return
-		}
-
-		if notification == nil {
-			logrus.Warnf("skipping notification")
-			return
-		}
-
-		notification.Level = opts.Level
-
-		logrus.Infof("Sending notification: %s", notification)
-		go func() {
-			pmb.SendNotification(conn, *notification)
-		}()
-	})
+	http.Handle("/", srv)
+	http.Handle("/status", statusHandler(conn, queue))
 
 	http.ListenAndServe(fmt.Sprintf("%s:%s", opts.Host, opts.Port), nil)
 }
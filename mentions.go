@@ -0,0 +1,169 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// mentionPrefix is added to the message of notifications that mention one
+// of the configured logins or teams.
+const mentionPrefix = "You were mentioned: "
+
+// mentionable is implemented by events that can address someone, either by
+// @-mentioning them in their text or by requesting their review or
+// assigning them.
+type mentionable interface {
+	// mentionText is the text written for this event that can contain
+	// @-mentions.
+	mentionText() []string
+
+	// addressees are the logins or "org/team" handles the event was
+	// directed at.
+	addressees() []string
+}
+
+// mentions matches the configured logins and teams.
+type mentions struct {
+	handles map[string]bool
+	pattern *regexp.Regexp
+}
+
+// compileMentions builds a matcher for handles, which may be given with or
+// without the leading @.
+func compileMentions(handles []string) *mentions {
+	if len(handles) == 0 {
+		return nil
+	}
+	m := &mentions{handles: make(map[string]bool)}
+	var quoted []string
+	for _, handle := range handles {
+		handle = strings.ToLower(strings.TrimPrefix(handle, "@"))
+		m.handles[handle] = true
+		quoted = append(quoted, regexp.QuoteMeta(handle))
+	}
+	m.pattern = regexp.MustCompile(`(?i)(?:^|[^\w@/-])@(?:` + strings.Join(quoted, "|") + `)(?:$|[^\w/-])`)
+	return m
+}
+
+// match reports whether the event the notification was built from
+// mentions one of the handles.
+func (m *mentions) match(data interface{}) bool {
+	event, ok := data.(mentionable)
+	if m == nil || !ok {
+		return false
+	}
+	for _, addressee := range event.addressees() {
+		if m.handles[strings.ToLower(addressee)] {
+			return true
+		}
+	}
+	for _, text := range event.mentionText() {
+		if m.pattern.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// Text is only considered when it was just written, so that labeling an
+// issue doesn't repeat a mention in its description.
+func newText(action string) bool {
+	return action == "opened" || action == "created" || action == "edited" || action == "submitted"
+}
+
+func (e *IssueCommentEvent) mentionText() []string {
+	if newText(e.Action) {
+		return []string{e.Comment.Body}
+	}
+	return nil
+}
+
+func (e *IssueCommentEvent) addressees() []string { return nil }
+
+func (e *PullRequestReviewCommentEvent) mentionText() []string {
+	if newText(e.Action) {
+		return []string{e.Comment.Body}
+	}
+	return nil
+}
+
+func (e *PullRequestReviewCommentEvent) addressees() []string { return nil }
+
+func (e *CommitCommentEvent) mentionText() []string {
+	if newText(e.Action) {
+		return []string{e.Comment.Body}
+	}
+	return nil
+}
+
+func (e *CommitCommentEvent) addressees() []string { return nil }
+
+func (e *DiscussionCommentEvent) mentionText() []string {
+	if newText(e.Action) {
+		return []string{e.Comment.Body}
+	}
+	return nil
+}
+
+func (e *DiscussionCommentEvent) addressees() []string { return nil }
+
+func (e *PullRequestReviewEvent) mentionText() []string {
+	if newText(e.Action) {
+		return []string{e.Review.Body}
+	}
+	return nil
+}
+
+func (e *PullRequestReviewEvent) addressees() []string { return nil }
+
+func (e *DiscussionEvent) mentionText() []string {
+	if newText(e.Action) {
+		return []string{e.Discussion.Title, e.Discussion.Body}
+	}
+	return nil
+}
+
+func (e *DiscussionEvent) addressees() []string { return nil }
+
+func (e *PullRequestEvent) mentionText() []string {
+	if newText(e.Action) {
+		return []string{e.PullRequest.Title, e.PullRequest.Body}
+	}
+	return nil
+}
+
+func (e *PullRequestEvent) addressees() []string {
+	switch {
+	case e.Action == "review_requested" && e.RequestedReviewer != nil:
+		return []string{e.RequestedReviewer.Login}
+	case e.Action == "review_requested" && e.RequestedTeam != nil:
+		return []string{teamHandle(e.Repository, e.RequestedTeam)}
+	case e.Action == "assigned" && e.Assignee != nil:
+		return []string{e.Assignee.Login}
+	}
+	return nil
+}
+
+func (e *IssuesEvent) mentionText() []string {
+	if newText(e.Action) {
+		return []string{e.Issue.Title, e.Issue.Body}
+	}
+	return nil
+}
+
+func (e *IssuesEvent) addressees() []string {
+	if e.Action == "assigned" && e.Assignee != nil {
+		return []string{e.Assignee.Login}
+	}
+	return nil
+}
+
+// teamHandle is how a team of the organization owning repository is
+// mentioned.
+func teamHandle(repository *Repository, team *Team) string {
+	owner := repository.FullName
+	if i := strings.Index(owner, "/"); i >= 0 {
+		owner = owner[:i]
+	}
+	return owner + "/" + team.Slug
+}
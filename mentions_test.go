@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestMentionsText(t *testing.T) {
+	m := compileMentions([]string{"@Octocat", "octo-org/reviewers"})
+
+	for _, tc := range []struct {
+		body      string
+		mentioned bool
+	}{
+		{"@octocat can you look at this?", true},
+		{"Thanks, @OctoCat.", true},
+		{"(cc @octo-org/reviewers)", true},
+		{"@octocats can you look at this?", false},
+		{"Mail octocat@octocat.com about it.", false},
+		{"See @octocat/other for details.", false},
+		{"No one in particular.", false},
+	} {
+		event := &IssueCommentEvent{Event: Event{Action: "created"}, Comment: &Comment{Body: tc.body}}
+		assert(t, m.match(event) == tc.mentioned, "Mention incorrect: "+tc.body)
+	}
+
+	var none *mentions
+	assert(t, !none.match(&IssueCommentEvent{Event: Event{Action: "created"}, Comment: &Comment{Body: "@octocat"}}), "mentioned without handles")
+}
+
+func TestMentionsOnlyNewText(t *testing.T) {
+	m := compileMentions([]string{"octocat"})
+
+	for _, tc := range []struct {
+		event     mentionable
+		mentioned bool
+	}{
+		{&IssueCommentEvent{Event: Event{Action: "edited"}, Comment: &Comment{Body: "@octocat"}}, true},
+		{&IssueCommentEvent{Event: Event{Action: "deleted"}, Comment: &Comment{Body: "@octocat"}}, false},
+		{&PullRequestReviewEvent{Event: Event{Action: "submitted"}, Review: &Review{Body: "@octocat"}}, true},
+		{&PullRequestReviewEvent{Event: Event{Action: "dismissed"}, Review: &Review{Body: "@octocat"}}, false},
+		{&PullRequestReviewCommentEvent{Event: Event{Action: "deleted"}, Comment: &Comment{Body: "@octocat"}}, false},
+		{&DiscussionCommentEvent{Event: Event{Action: "deleted"}, Comment: &Comment{Body: "@octocat"}}, false},
+	} {
+		assert(t, m.match(tc.event) == tc.mentioned, fmt.Sprintf("Mention incorrect for %T", tc.event))
+	}
+}
+
+func TestMentionsAddressees(t *testing.T) {
+	m := compileMentions([]string{"octocat", "codertocat/justice-league"})
+
+	for _, tc := range []struct {
+		event, json, action, extra string
+		mentioned                  bool
+	}{
+		{"pull_request", pullRequestActionJSON, "review_requested", `"requested_reviewer":{"login":"octocat","id":1,"type":"User"},`, true},
+		{"pull_request", pullRequestActionJSON, "review_requested", `"requested_reviewer":{"login":"hubot","id":2,"type":"User"},`, false},
+		{"pull_request", pullRequestActionJSON, "review_requested", `"requested_team":{"name":"Justice League","id":1,"slug":"justice-league"},`, true},
+		{"pull_request", pullRequestActionJSON, "assigned", `"assignee":{"login":"octocat","id":1,"type":"User"},`, true},
+		{"issues", issueActionJSON, "assigned", `"assignee":{"login":"octocat","id":1,"type":"User"},`, true},
+		{"issues", issueActionJSON, "unassigned", `"assignee":{"login":"octocat","id":1,"type":"User"},`, false},
+		{"issues", issueActionJSON, "labeled", `"label":{"id":1362934389,"name":"bug","color":"d73a4a"},`, false},
+	} {
+		_, meta, err := parseEvent(tc.event, fmt.Sprintf(tc.json, tc.action, tc.extra))
+
+		if err != nil {
+			t.Errorf("Error: %s", err)
+			continue
+		}
+
+		assert(t, m.match(meta.Data) == tc.mentioned, "Mention incorrect for "+tc.event+":"+tc.action)
+	}
+}
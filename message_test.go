@@ -1,6 +1,13 @@
 package main
 
-import "testing"
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/justone/pmb-gh/webhook"
+	"github.com/justone/pmb/api"
+)
 
 func assert(t *testing.T, cond bool, msg string) {
 	if !cond {
@@ -51,7 +58,7 @@ func TestPush(t *testing.T) {
 		t.Errorf("Error: %s", err)
 	}
 
-	assert(t, note.Message == "Push 1 commit(s) to changes in baxterthehacker/public-repo by baxterthehacker.", "Message incorrect")
+	assert(t, note.Message == "Push 1 commit(s) to changes in baxterthehacker/public-repo by baxterthehacker: Update README.md", "Message incorrect")
 	assert(t, note.URL == "https://github.com/baxterthehacker/public-repo/compare/9049f1265b7d...0d1a26e67d8f", "URL incorrect")
 }
 
@@ -179,3 +186,234 @@ func TestPing(t *testing.T) {
 	assert(t, note.Message == "Ping for owninguser/repositoryname by owninguser. Zen: Favor focus over features.", "Message incorrect")
 	assert(t, note.URL == "https://github.com/owninguser/repositoryname", "URL incorrect")
 }
+
+func TestMissingField(t *testing.T) {
+	_, _, err := parseEvent("pull_request", `{"action":"opened","pull_request":{"number":1,"title":"Update the README"},"repository":{"full_name":"baxterthehacker/public-repo"},"sender":{"login":"baxterthehacker"}}`)
+
+	assert(t, err != nil && err.Error() == "Unable to get pull_request.html_url: missing from payload", "Missing field not reported")
+
+	_, _, err = parseEvent("watch", `{"action":"started","repository":{"full_name":"owninguser/repositoryname"},"sender":{}}`)
+
+	assert(t, err != nil && err.Error() == "Unable to get sender.login: missing from payload", "Missing sender not reported")
+}
+
+func TestUnhandledEvent(t *testing.T) {
+	note, meta, err := parseEvent("sponsorship", `{"action":"created","repository":{"full_name":"baxterthehacker/public-repo"},"sender":{"login":"baxterthehacker","type":"User"}}`)
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+
+	assert(t, note.Message == "Unhandled event sponsorship for baxterthehacker/public-repo by baxterthehacker.", "Message incorrect")
+	assert(t, note.URL == "", "URL incorrect")
+	assert(t, meta.Event == "sponsorship" && meta.Sender == "baxterthehacker" && meta.SenderType == "User", "Metadata incorrect")
+}
+
+func TestRegisterHandler(t *testing.T) {
+	RegisterHandler("internal_audit", EventHandlerFunc(func(name string, payload []byte) (*pmb.Notification, *Metadata, error) {
+		return &pmb.Notification{Message: "Audit finished"}, nil, nil
+	}))
+	defer webhook.Unregister("internal_audit")
+
+	note, meta, err := parseEvent("internal_audit", `{}`)
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+
+	assert(t, note.Message == "Audit finished", "Message incorrect")
+	assert(t, meta.Event == "internal_audit", "Metadata incorrect")
+}
+
+func TestPullRequestMetadata(t *testing.T) {
+	_, meta, err := parseEvent("pull_request", `{"action":"labeled","pull_request":{"number":1,"title":"Update the README with new information","html_url":"https://github.com/baxterthehacker/public-repo/pull/1","labels":[{"name":"bug"}],"base":{"ref":"master"}},"label":{"name":"bug"},"repository":{"full_name":"baxterthehacker/public-repo"},"sender":{"login":"baxterthehacker"}}`)
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+
+	assert(t, meta.Ref == "master", "Ref incorrect")
+	assert(t, len(meta.Labels) == 1 && meta.Labels[0] == "bug", "Labels incorrect")
+	_, ok := meta.Data.(*PullRequestEvent)
+	assert(t, ok, "Data incorrect")
+}
+
+func TestCommitComment(t *testing.T) {
+	name := "commit_comment"
+	json := `{"action":"created","comment":{"url":"https://api.github.com/repos/Codertocat/Hello-World/comments/33548674","html_url":"https://github.com/Codertocat/Hello-World/commit/6113728f27ae82c7b1a177c8d03f9e96e0adf246#commitcomment-33548674","id":33548674,"node_id":"MDEzOkNvbW1pdENvbW1lbnQzMzU0ODY3NA==","user":{"login":"Codertocat","id":21031067,"type":"User","site_admin":false},"position":4,"line":12,"path":"README.md","commit_id":"6113728f27ae82c7b1a177c8d03f9e96e0adf246","created_at":"2019-05-15T15:20:39Z","updated_at":"2019-05-15T15:20:39Z","author_association":"OWNER","body":"This is a really good change! :+1:"},"repository":{"id":186853002,"node_id":"MDEwOlJlcG9zaXRvcnkxODY4NTMwMDI=","name":"Hello-World","full_name":"Codertocat/Hello-World","private":false,"owner":{"login":"Codertocat","id":21031067,"type":"User","site_admin":false},"html_url":"https://github.com/Codertocat/Hello-World","default_branch":"master"},"sender":{"login":"Codertocat","id":21031067,"html_url":"https://github.com/Codertocat","type":"User","site_admin":false}}`
+	note, _, err := parseEvent(name, json)
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+
+	assert(t, note.Message == "Comment created on commit 6113728 (README.md:12) on Codertocat/Hello-World by Codertocat: This is a really good change! :+1:", "Message incorrect")
+	assert(t, note.URL == "https://github.com/Codertocat/Hello-World/commit/6113728f27ae82c7b1a177c8d03f9e96e0adf246#commitcomment-33548674", "URL incorrect")
+}
+
+const pullRequestActionJSON = `{"action":"%s","number":5,"pull_request":{"url":"https://api.github.com/repos/Codertocat/Hello-World/pulls/5","id":279147437,"html_url":"https://github.com/Codertocat/Hello-World/pull/5","number":5,"state":"open","locked":false,"title":"Update the README with new information.","user":{"login":"Codertocat","id":21031067,"type":"User"},"body":"This is a pretty simple change that we need to pull into master.","labels":[],"draft":false,"merged":false,"merged_by":null,"auto_merge":null,"head":{"label":"Codertocat:changes","ref":"changes","sha":"ec26c3e57ca3a959ca5aad62de7213c562f8c821"},"base":{"label":"Codertocat:master","ref":"master","sha":"f95f852bd8fca8fcc58a9a2d6c842781e32a215e"}},%s"repository":{"id":186853002,"name":"Hello-World","full_name":"Codertocat/Hello-World","private":false,"owner":{"login":"Codertocat","id":21031067,"type":"User"},"html_url":"https://github.com/Codertocat/Hello-World"},"sender":{"login":"Codertocat","id":21031067,"html_url":"https://github.com/Codertocat","type":"User"}}`
+
+func TestPullRequestActions(t *testing.T) {
+	merged := strings.Replace(pullRequestActionJSON, `"merged":false,"merged_by":null`, `"merged":true,"merged_by":{"login":"octocat","id":1,"type":"User"}`, 1)
+	autoMerge := strings.Replace(pullRequestActionJSON, `"auto_merge":null`, `"auto_merge":{"enabled_by":{"login":"Codertocat","id":21031067,"type":"User"},"merge_method":"squash","commit_title":null,"commit_message":null}`, 1)
+
+	for _, tc := range []struct {
+		template, action, extra string
+		message, url            string
+	}{
+		{merged, "closed", "", "Pull request merged #5 (Update the README wi...) into master on Codertocat/Hello-World by octocat.", "https://github.com/Codertocat/Hello-World/pull/5"},
+		{pullRequestActionJSON, "closed", "", "Pull request closed without merging #5 (Update the README wi...) on Codertocat/Hello-World by Codertocat.", "https://github.com/Codertocat/Hello-World/pull/5"},
+		{pullRequestActionJSON, "review_requested", `"requested_reviewer":{"login":"octocat","id":1,"type":"User"},`, "Review requested from octocat on pull request #5 (Update the README wi...) on Codertocat/Hello-World by Codertocat.", "https://github.com/Codertocat/Hello-World/pull/5"},
+		{pullRequestActionJSON, "review_requested", `"requested_team":{"name":"Justice League","id":1,"slug":"justice-league"},`, "Review requested from Justice League on pull request #5 (Update the README wi...) on Codertocat/Hello-World by Codertocat.", "https://github.com/Codertocat/Hello-World/pull/5"},
+		{pullRequestActionJSON, "labeled", `"label":{"id":1362934389,"name":"bug","color":"d73a4a"},`, "Pull request #5 (Update the README wi...) labeled bug on Codertocat/Hello-World by Codertocat.", "https://github.com/Codertocat/Hello-World/pull/5"},
+		{pullRequestActionJSON, "synchronize", `"before":"6113728f27ae82c7b1a177c8d03f9e96e0adf246","after":"ec26c3e57ca3a959ca5aad62de7213c562f8c821",`, "Pull request #5 (Update the README wi...) updated to ec26c3e on Codertocat/Hello-World by Codertocat.", "https://github.com/Codertocat/Hello-World/pull/5/files"},
+		{pullRequestActionJSON, "ready_for_review", "", "Pull request #5 (Update the README wi...) ready for review on Codertocat/Hello-World by Codertocat.", "https://github.com/Codertocat/Hello-World/pull/5"},
+		{pullRequestActionJSON, "converted_to_draft", "", "Pull request #5 (Update the README wi...) converted to draft on Codertocat/Hello-World by Codertocat.", "https://github.com/Codertocat/Hello-World/pull/5"},
+		{autoMerge, "auto_merge_enabled", "", "Auto-merge (squash) enabled for pull request #5 (Update the README wi...) on Codertocat/Hello-World by Codertocat.", "https://github.com/Codertocat/Hello-World/pull/5"},
+		{pullRequestActionJSON, "auto_merge_disabled", `"reason":"Base branch was modified",`, "Auto-merge disabled for pull request #5 (Update the README wi...) on Codertocat/Hello-World by Codertocat: Base branch was modified.", "https://github.com/Codertocat/Hello-World/pull/5"},
+	} {
+		note, _, err := parseEvent("pull_request", fmt.Sprintf(tc.template, tc.action, tc.extra))
+
+		if err != nil {
+			t.Errorf("Error: %s", err)
+			continue
+		}
+
+		assert(t, note.Message == tc.message, "Message incorrect: "+note.Message)
+		assert(t, note.URL == tc.url, "URL incorrect: "+note.URL)
+	}
+}
+
+func TestPullRequestMerged(t *testing.T) {
+	json := strings.Replace(pullRequestActionJSON, `"merged":false`, `"merged":true`, 1)
+	_, meta, err := parseEvent("pull_request", fmt.Sprintf(json, "closed", ""))
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+
+	assert(t, meta.Action == "closed" && meta.State == "merged", "Metadata incorrect")
+}
+
+const issueActionJSON = `{"action":"%s","issue":{"url":"https://api.github.com/repos/Codertocat/Hello-World/issues/1","html_url":"https://github.com/Codertocat/Hello-World/issues/1","id":444500041,"node_id":"MDU6SXNzdWU0NDQ1MDAwNDE=","number":1,"title":"Spelling error in the README file","user":{"login":"Codertocat","id":21031067,"type":"User"},"labels":[{"id":1362934389,"name":"bug","color":"d73a4a","default":true}],"state":"open","locked":false,"assignee":{"login":"octocat","id":1,"type":"User"},"assignees":[{"login":"octocat","id":1,"type":"User"}],"milestone":null,"comments":0,"created_at":"2019-05-15T15:20:18Z","updated_at":"2019-05-15T15:20:18Z","closed_at":null,"author_association":"OWNER","body":"It looks like you accidently spelled 'commit' with two 't's.","state_reason":null},%s"repository":{"id":186853002,"name":"Hello-World","full_name":"Codertocat/Hello-World","private":false,"owner":{"login":"Codertocat","id":21031067,"type":"User"},"html_url":"https://github.com/Codertocat/Hello-World"},"sender":{"login":"Codertocat","id":21031067,"html_url":"https://github.com/Codertocat","type":"User"}}`
+
+func TestIssuesActions(t *testing.T) {
+	closed := strings.Replace(issueActionJSON, `"state_reason":null`, `"state_reason":"not_planned"`, 1)
+
+	for _, tc := range []struct {
+		template, action, extra string
+		message, url            string
+	}{
+		{issueActionJSON, "labeled", `"label":{"id":1362934389,"name":"bug","color":"d73a4a"},`, "Issue 1 (Spelling error in th...) labeled bug on Codertocat/Hello-World by Codertocat.", "https://github.com/Codertocat/Hello-World/issues/1"},
+		{issueActionJSON, "assigned", `"assignee":{"login":"octocat","id":1,"type":"User"},`, "Issue 1 (Spelling error in th...) assigned to octocat on Codertocat/Hello-World by Codertocat.", "https://github.com/Codertocat/Hello-World/issues/1"},
+		{issueActionJSON, "unassigned", `"assignee":{"login":"hubot","id":2,"type":"User"},`, "Issue 1 (Spelling error in th...) unassigned from hubot on Codertocat/Hello-World by Codertocat.", "https://github.com/Codertocat/Hello-World/issues/1"},
+		{issueActionJSON, "milestoned", `"milestone":{"number":1,"title":"v1.0","state":"open","html_url":"https://github.com/Codertocat/Hello-World/milestone/1"},`, "Issue 1 (Spelling error in th...) added to milestone v1.0 on Codertocat/Hello-World by Codertocat.", "https://github.com/Codertocat/Hello-World/issues/1"},
+		{issueActionJSON, "transferred", `"changes":{"new_issue":{"number":7,"title":"Spelling error in the README file","html_url":"https://github.com/Codertocat/Docs/issues/7"},"new_repository":{"full_name":"Codertocat/Docs","html_url":"https://github.com/Codertocat/Docs"}},`, "Issue 1 (Spelling error in th...) transferred from Codertocat/Hello-World to Codertocat/Docs by Codertocat.", "https://github.com/Codertocat/Docs/issues/7"},
+		{issueActionJSON, "pinned", "", "Issue 1 (Spelling error in th...) pinned on Codertocat/Hello-World by Codertocat.", "https://github.com/Codertocat/Hello-World/issues/1"},
+		{closed, "closed", "", "Issue 1 (Spelling error in th...) closed as not planned on Codertocat/Hello-World by Codertocat.", "https://github.com/Codertocat/Hello-World/issues/1"},
+	} {
+		note, meta, err := parseEvent("issues", fmt.Sprintf(tc.template, tc.action, tc.extra))
+
+		if err != nil {
+			t.Errorf("Error: %s", err)
+			continue
+		}
+
+		assert(t, note.Message == tc.message, "Message incorrect: "+note.Message)
+		assert(t, note.URL == tc.url, "URL incorrect: "+note.URL)
+		if tc.action == "closed" {
+			assert(t, meta.State == "not_planned", "Metadata incorrect")
+		}
+	}
+}
+
+func TestIssuesAssigneeFilter(t *testing.T) {
+	opts.IssueAssignees = []string{"hubot"}
+	defer func() { opts.IssueAssignees = nil }()
+	defer withDefaultSettings(t)()
+
+	note, _, err := parseEvent("issues", fmt.Sprintf(issueActionJSON, "labeled", ""))
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+	assert(t, note == nil, "issue assigned to someone else notified")
+
+	note, _, err = parseEvent("issues", fmt.Sprintf(issueActionJSON, "unassigned", `"assignee":{"login":"hubot","id":2,"type":"User"},`))
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+	assert(t, note != nil, "unassignment of configured login not notified")
+
+	json := strings.Replace(issueActionJSON, `"assignees":[{"login":"octocat"`, `"assignees":[{"login":"hubot"`, 1)
+	note, _, err = parseEvent("issues", fmt.Sprintf(json, "labeled", ""))
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+	assert(t, note != nil, "issue assigned to configured login not notified")
+}
+
+const pushCommitJSON = `{"id":"%s","tree_id":"f9d2a07e9488b91af2641b26b9407fe22a451433","distinct":true,"message":"%s","timestamp":"2019-05-15T15:20:30Z","url":"https://github.com/Codertocat/Hello-World/commit/%[1]s","author":{"name":"%s","email":"%[3]s@users.noreply.github.com","username":"%[3]s"},"committer":{"name":"GitHub","email":"noreply@github.com","username":"web-flow"},"added":[],"removed":[],"modified":["README.md"]}`
+
+func pushJSON(ref string, forced bool, commits ...string) string {
+	head := "null"
+	if len(commits) > 0 {
+		head = commits[len(commits)-1]
+	}
+	return fmt.Sprintf(`{"ref":"%s","before":"6113728f27ae82c7b1a177c8d03f9e96e0adf246","after":"0000000000000000000000000000000000000000","created":false,"deleted":false,"forced":%t,"base_ref":null,"compare":"https://github.com/Codertocat/Hello-World/compare/6113728f27ae...000000000000","commits":[%s],"head_commit":%s,"repository":{"id":186853002,"name":"Hello-World","full_name":"Codertocat/Hello-World","html_url":"https://github.com/Codertocat/Hello-World"},"pusher":{"name":"Codertocat","email":"21031067+Codertocat@users.noreply.github.com"},"sender":{"login":"Codertocat","id":21031067,"type":"User"}}`,
+		ref, forced, strings.Join(commits, ","), head)
+}
+
+func TestPushCommitSummaries(t *testing.T) {
+	opts.PushCommits = 2
+	defer func() { opts.PushCommits = 0 }()
+	defer withDefaultSettings(t)()
+
+	json := pushJSON("refs/heads/main", false,
+		fmt.Sprintf(pushCommitJSON, "1111111aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", `Fix typo in README\n\nIt said commmit.`, "Codertocat"),
+		fmt.Sprintf(pushCommitJSON, "2222222bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", "Add contributing guide", "octocat"),
+		fmt.Sprintf(pushCommitJSON, "3333333ccccccccccccccccccccccccccccccccc", "Bump version", "Codertocat"),
+	)
+	note, _, err := parseEvent("push", json)
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+
+	assert(t, note.Message == "Push 3 commit(s) to main in Codertocat/Hello-World by Codertocat (authors: Codertocat, octocat): Bump version\n"+
+		"1111111 Fix typo in README\n"+
+		"2222222 Add contributing guide\n"+
+		"+1 more", "Message incorrect: "+note.Message)
+}
+
+func TestPushForced(t *testing.T) {
+	note, meta, err := parseEvent("push", pushJSON("refs/heads/main", true))
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+
+	assert(t, note != nil && note.Message == "Force-push to main in Codertocat/Hello-World by Codertocat.", "Message incorrect")
+	assert(t, meta.Ref == "main", "Metadata incorrect")
+
+	note, _, err = parseEvent("push", pushJSON("refs/heads/main", false))
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+	assert(t, note == nil, "empty push notified")
+}
+
+func TestPushTag(t *testing.T) {
+	json := pushJSON("refs/tags/v1.2.0", false, fmt.Sprintf(pushCommitJSON, "1111111aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", "Release 1.2.0", "octocat"))
+	note, meta, err := parseEvent("push", json)
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+
+	assert(t, note.Message == "Push 1 commit(s) to tag v1.2.0 in Codertocat/Hello-World by Codertocat (authors: octocat): Release 1.2.0", "Message incorrect: "+note.Message)
+	assert(t, meta.Ref == "v1.2.0", "Metadata incorrect")
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/justone/pmb/api"
+)
+
+// outboxEntry is a notification waiting to be sent to PMB.
+type outboxEntry struct {
+	ID           uint64           `json:"id"`
+	Notification pmb.Notification `json:"notification"`
+	Queued       time.Time        `json:"queued"`
+
+	attempts  int
+	lastError string
+}
+
+// outboxRecord is a line of the outbox file. Entries are added with an
+// "add" record and removed with a "done" record once sent.
+type outboxRecord struct {
+	Op    string       `json:"op"`
+	Entry *outboxEntry `json:"entry,omitempty"`
+	ID    uint64       `json:"id,omitempty"`
+}
+
+// outbox queues notifications until they have been sent to PMB, retrying
+// with exponential backoff. When it has a path the queue is kept in an
+// append-only file, so that it survives a restart.
+type outbox struct {
+	mu      sync.Mutex
+	file    *os.File
+	pending []*outboxEntry
+	nextID  uint64
+	wake    chan struct{}
+	retry   chan struct{}
+
+	send       func(pmb.Notification) error
+	minBackoff time.Duration
+	maxBackoff time.Duration
+
+	delivered uint64
+	failures  uint64
+}
+
+func openOutbox(path string, send func(pmb.Notification) error, minBackoff, maxBackoff time.Duration) (*outbox, error) {
+	o := &outbox{
+		nextID:     1,
+		wake:       make(chan struct{}, 1),
+		retry:      make(chan struct{}, 1),
+		send:       send,
+		minBackoff: minBackoff,
+		maxBackoff: maxBackoff,
+	}
+	if path == "" {
+		return o, nil
+	}
+
+	if err := o.load(path); err != nil {
+		return nil, err
+	}
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to open outbox: %s", err)
+	}
+	o.file = file
+	if len(o.pending) > 0 {
+		logrus.Infof("Loaded %d queued notification(s) from %s", len(o.pending), path)
+	}
+	return o, nil
+}
+
+// load replays the outbox file at path and rewrites it with only the
+// entries that are still pending.
+func (o *outbox) load(path string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("Unable to read outbox: %s", err)
+	}
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var record outboxRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			logrus.Warnf("Skipping corrupt outbox record: %s", err)
+			continue
+		}
+		switch {
+		case record.Op == "add" && record.Entry != nil:
+			o.pending = append(o.pending, record.Entry)
+			if record.Entry.ID >= o.nextID {
+				o.nextID = record.Entry.ID + 1
+			}
+		case record.Op == "done":
+			o.remove(record.ID)
+		}
+	}
+	f.Close()
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("Unable to read outbox: %s", err)
+	}
+
+	tmp := path + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_TRUNC|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("Unable to compact outbox: %s", err)
+	}
+	w := bufio.NewWriter(out)
+	for _, entry := range o.pending {
+		if err := writeRecord(w, outboxRecord{Op: "add", Entry: entry}); err != nil {
+			out.Close()
+			return fmt.Errorf("Unable to compact outbox: %s", err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		out.Close()
+		return fmt.Errorf("Unable to compact outbox: %s", err)
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return fmt.Errorf("Unable to compact outbox: %s", err)
+	}
+	out.Close()
+	return os.Rename(tmp, path)
+}
+
+func writeRecord(w io.Writer, record outboxRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// Enqueue adds a notification to the outbox. Once it returns without an
+// error the notification has been written to disk.
+func (o *outbox) Enqueue(note pmb.Notification) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	entry := &outboxEntry{
+		ID:           o.nextID,
+		Notification: note,
+		Queued:       time.Now(),
+	}
+	if o.file != nil {
+		if err := writeRecord(o.file, outboxRecord{Op: "add", Entry: entry}); err != nil {
+			return fmt.Errorf("Unable to write to outbox: %s", err)
+		}
+		if err := o.file.Sync(); err != nil {
+			return fmt.Errorf("Unable to write to outbox: %s", err)
+		}
+	}
+	o.nextID++
+	o.pending = append(o.pending, entry)
+
+	select {
+	case o.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Retry cuts short the backoff after a failed send, for when whatever made
+// it fail has been dealt with, such as the connection to PMB coming back.
+func (o *outbox) Retry() {
+	select {
+	case o.retry <- struct{}{}:
+	default:
+	}
+}
+
+// Run sends queued notifications in order until stop is closed, backing off
+// while sending fails.
+func (o *outbox) Run(stop <-chan struct{}) {
+	backoff := o.minBackoff
+	for {
+		entry := o.next()
+		if entry == nil {
+			select {
+			case <-o.wake:
+				continue
+			case <-stop:
+				return
+			}
+		}
+
+		err := o.send(entry.Notification)
+		if err == nil {
+			o.done(entry)
+			backoff = o.minBackoff
+			continue
+		}
+
+		o.failed(entry, err)
+		logrus.Warnf("Unable to send notification %d (attempt %d), retrying in %s: %s", entry.ID, entry.attempts, backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-o.retry:
+			backoff = o.minBackoff
+			continue
+		case <-stop:
+			return
+		}
+		backoff *= 2
+		if backoff > o.maxBackoff {
+			backoff = o.maxBackoff
+		}
+	}
+}
+
+func (o *outbox) next() *outboxEntry {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if len(o.pending) == 0 {
+		return nil
+	}
+	return o.pending[0]
+}
+
+func (o *outbox) failed(entry *outboxEntry, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	entry.attempts++
+	entry.lastError = err.Error()
+	o.failures++
+}
+
+func (o *outbox) done(entry *outboxEntry) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.remove(entry.ID)
+	o.delivered++
+	if o.file == nil {
+		return
+	}
+
+	// Once everything has been sent the file can start over.
+	var err error
+	if len(o.pending) == 0 {
+		err = o.file.Truncate(0)
+	} else {
+		err = writeRecord(o.file, outboxRecord{Op: "done", ID: entry.ID})
+	}
+	if err != nil {
+		logrus.Warnf("Unable to record sent notification %d, it may be sent again: %s", entry.ID, err)
+	}
+}
+
+func (o *outbox) remove(id uint64) {
+	for i, entry := range o.pending {
+		if entry.ID == id {
+			o.pending = append(o.pending[:i], o.pending[i+1:]...)
+			return
+		}
+	}
+}
+
+// outboxStatus is reported by the status endpoint.
+type outboxStatus struct {
+	Pending   int                 `json:"pending"`
+	Delivered uint64              `json:"delivered"`
+	Failures  uint64              `json:"failures"`
+	Queue     []outboxEntryStatus `json:"queue"`
+}
+
+type outboxEntryStatus struct {
+	ID        uint64    `json:"id"`
+	Queued    time.Time `json:"queued"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+func (o *outbox) Status() outboxStatus {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	status := outboxStatus{
+		Pending:   len(o.pending),
+		Delivered: o.delivered,
+		Failures:  o.failures,
+		Queue:     []outboxEntryStatus{},
+	}
+	for _, entry := range o.pending {
+		status.Queue = append(status.Queue, outboxEntryStatus{
+			ID:        entry.ID,
+			Queued:    entry.Queued,
+			Attempts:  entry.attempts,
+			LastError: entry.lastError,
+		})
+	}
+	return status
+}
+
+func (o *outbox) Close() error {
+	if o.file == nil {
+		return nil
+	}
+	return o.file.Close()
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/justone/pmb/api"
+)
+
+func TestOutboxRetries(t *testing.T) {
+	sent := make(chan pmb.Notification, 1)
+	failures := 2
+	o, err := openOutbox("", func(note pmb.Notification) error {
+		if failures > 0 {
+			failures--
+			return errors.New("connection refused")
+		}
+		sent <- note
+		return nil
+	}, time.Millisecond, 4*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go o.Run(stop)
+
+	o.Enqueue(pmb.Notification{Message: "New star for owninguser/repositoryname by otheruser."})
+
+	select {
+	case note := <-sent:
+		assert(t, note.Message == "New star for owninguser/repositoryname by otheruser.", "Message incorrect")
+	case <-time.After(time.Second):
+		t.Fatalf("notification not sent")
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	status := o.Status()
+	assert(t, status.Pending == 0 && status.Delivered == 1 && status.Failures == 2, "status incorrect")
+}
+
+func TestOutboxPersisted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "pmb-gh")
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "outbox")
+
+	fail := func(pmb.Notification) error { return errors.New("connection refused") }
+	o, err := openOutbox(path, fail, time.Second, time.Second)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	o.Enqueue(pmb.Notification{Message: "first", Level: 4})
+	o.Enqueue(pmb.Notification{Message: "second", Level: 5})
+	o.done(o.next())
+	o.Close()
+
+	o, err = openOutbox(path, fail, time.Second, time.Second)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	defer o.Close()
+
+	assert(t, len(o.pending) == 1, "pending notifications not restored")
+	assert(t, o.pending[0].Notification.Message == "second" && o.pending[0].Notification.Level == 5, "wrong notification restored")
+
+	o.Enqueue(pmb.Notification{Message: "third"})
+	assert(t, o.pending[1].ID == 3, "IDs reused after restart")
+}
@@ -0,0 +1,35 @@
+package main
+
+func init() {
+	RegisterHandler("release", payloadHandler(func() eventPayload { return &ReleaseEvent{} }))
+	registerTemplates(map[string]messageTemplate{
+		"release": {
+			Message: "Release {{.Action}} {{.Release.TagName}}{{if and .Release.Name (ne .Release.Name .Release.TagName)}} ({{truncate .Release.Name 30}}){{end}}" +
+				"{{if .Release.Draft}} [draft]{{end}}{{if .Release.Prerelease}} [prerelease]{{end}}" +
+				" on {{.Repository.FullName}} by {{.Sender.Login}}.",
+			URL: "{{.Release.HTMLURL}}",
+		},
+	})
+}
+
+// Release is a GitHub release.
+type Release struct {
+	TagName         string `json:"tag_name" required:"true"`
+	Name            string `json:"name"`
+	Body            string `json:"body"`
+	Draft           bool   `json:"draft"`
+	Prerelease      bool   `json:"prerelease"`
+	TargetCommitish string `json:"target_commitish"`
+	HTMLURL         string `json:"html_url" required:"true"`
+	Author          *User  `json:"author"`
+}
+
+// ReleaseEvent is the payload of release events.
+type ReleaseEvent struct {
+	Event
+	Release *Release `json:"release" required:"true"`
+}
+
+func (e *ReleaseEvent) describe(meta *Metadata) {
+	meta.Ref = e.Release.TagName
+}
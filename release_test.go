@@ -0,0 +1,37 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const releaseJSON = `{"action":"published","release":{"url":"https://api.github.com/repos/Codertocat/Hello-World/releases/11248810","assets_url":"https://api.github.com/repos/Codertocat/Hello-World/releases/11248810/assets","upload_url":"https://uploads.github.com/repos/Codertocat/Hello-World/releases/11248810/assets{?name,label}","html_url":"https://github.com/Codertocat/Hello-World/releases/tag/0.0.1","id":11248810,"node_id":"MDc6UmVsZWFzZTExMjQ4ODEw","tag_name":"0.0.1","target_commitish":"master","name":null,"draft":false,"author":{"login":"Codertocat","id":21031067,"node_id":"MDQ6VXNlcjIxMDMxMDY3","avatar_url":"https://avatars1.githubusercontent.com/u/21031067?v=4","gravatar_id":"","url":"https://api.github.com/users/Codertocat","html_url":"https://github.com/Codertocat","followers_url":"https://api.github.com/users/Codertocat/followers","following_url":"https://api.github.com/users/Codertocat/following{/other_user}","gists_url":"https://api.github.com/users/Codertocat/gists{/gist_id}","starred_url":"https://api.github.com/users/Codertocat/starred{/owner}{/repo}","subscriptions_url":"https://api.github.com/users/Codertocat/subscriptions","organizations_url":"https://api.github.com/users/Codertocat/orgs","repos_url":"https://api.github.com/users/Codertocat/repos","events_url":"https://api.github.com/users/Codertocat/events{/privacy}","received_events_url":"https://api.github.com/users/Codertocat/received_events","type":"User","site_admin":false},"prerelease":false,"created_at":"2019-05-15T15:19:25Z","published_at":"2019-05-15T15:20:53Z","assets":[],"tarball_url":"https://api.github.com/repos/Codertocat/Hello-World/tarball/0.0.1","zipball_url":"https://api.github.com/repos/Codertocat/Hello-World/zipball/0.0.1","body":null},"repository":{"id":186853002,"node_id":"MDEwOlJlcG9zaXRvcnkxODY4NTMwMDI=","name":"Hello-World","full_name":"Codertocat/Hello-World","private":false,"owner":{"login":"Codertocat","id":21031067,"node_id":"MDQ6VXNlcjIxMDMxMDY3","avatar_url":"https://avatars1.githubusercontent.com/u/21031067?v=4","gravatar_id":"","url":"https://api.github.com/users/Codertocat","html_url":"https://github.com/Codertocat","type":"User","site_admin":false},"html_url":"https://github.com/Codertocat/Hello-World","description":null,"fork":false,"url":"https://api.github.com/repos/Codertocat/Hello-World","created_at":"2019-05-15T15:19:25Z","updated_at":"2019-05-15T15:20:41Z","pushed_at":"2019-05-15T15:20:52Z","git_url":"git://github.com/Codertocat/Hello-World.git","ssh_url":"git@github.com:Codertocat/Hello-World.git","clone_url":"https://github.com/Codertocat/Hello-World.git","svn_url":"https://github.com/Codertocat/Hello-World","homepage":null,"size":0,"stargazers_count":0,"watchers_count":0,"language":"Ruby","has_issues":true,"has_projects":true,"has_downloads":true,"has_wiki":true,"has_pages":true,"forks_count":1,"mirror_url":null,"archived":false,"disabled":false,"open_issues_count":2,"license":null,"forks":1,"open_issues":2,"watchers":0,"default_branch":"master"},"sender":{"login":"Codertocat","id":21031067,"node_id":"MDQ6VXNlcjIxMDMxMDY3","avatar_url":"https://avatars1.githubusercontent.com/u/21031067?v=4","gravatar_id":"","url":"https://api.github.com/users/Codertocat","html_url":"https://github.com/Codertocat","followers_url":"https://api.github.com/users/Codertocat/followers","following_url":"https://api.github.com/users/Codertocat/following{/other_user}","gists_url":"https://api.github.com/users/Codertocat/gists{/gist_id}","starred_url":"https://api.github.com/users/Codertocat/starred{/owner}{/repo}","subscriptions_url":"https://api.github.com/users/Codertocat/subscriptions","organizations_url":"https://api.github.com/users/Codertocat/orgs","repos_url":"https://api.github.com/users/Codertocat/repos","events_url":"https://api.github.com/users/Codertocat/events{/privacy}","received_events_url":"https://api.github.com/users/Codertocat/received_events","type":"User","site_admin":false}}`
+
+func TestRelease(t *testing.T) {
+	name := "release"
+	note, meta, err := parseEvent(name, releaseJSON)
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+
+	assert(t, note.Message == "Release published 0.0.1 on Codertocat/Hello-World by Codertocat.", "Message incorrect")
+	assert(t, note.URL == "https://github.com/Codertocat/Hello-World/releases/tag/0.0.1", "URL incorrect")
+	assert(t, meta.Ref == "0.0.1", "Ref incorrect")
+}
+
+func TestReleasePrerelease(t *testing.T) {
+	name := "release"
+	json := strings.NewReplacer(
+		`"action":"published"`, `"action":"prereleased"`,
+		`"name":null`, `"name":"First beta"`,
+		`"prerelease":false`, `"prerelease":true`,
+	).Replace(releaseJSON)
+	note, _, err := parseEvent(name, json)
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+
+	assert(t, note.Message == "Release prereleased 0.0.1 (First beta) [prerelease] on Codertocat/Hello-World by Codertocat.", "Message incorrect")
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+
+	"github.com/justone/pmb/api"
+)
+
+// RuleConfig is a routing rule in the configuration file. Every field that
+// is set must match for the rule to apply; the patterns are globs where *
+// matches within a path segment and ** matches across them.
+type RuleConfig struct {
+	Repository  string `yaml:"repository"`
+	Event       string `yaml:"event"`
+	Action      string `yaml:"action"`
+	Sender      string `yaml:"sender"`
+	Branch      string `yaml:"branch"`
+	Label       string `yaml:"label"`
+	Environment string `yaml:"environment"`
+
+	// Paths matches pushes changing any file that matches one of the
+	// patterns, AllPaths pushes where every changed file matches one.
+	Paths    []string `yaml:"paths"`
+	AllPaths []string `yaml:"all_paths"`
+
+	// Then is "drop" or "send". Either stops rule processing; rules
+	// without it only set the level or template and processing continues.
+	Then     string           `yaml:"then"`
+	Level    *float64         `yaml:"level"`
+	Template *messageTemplate `yaml:"template"`
+}
+
+type rule struct {
+	repository  *regexp.Regexp
+	event       *regexp.Regexp
+	action      *regexp.Regexp
+	sender      *regexp.Regexp
+	branch      *regexp.Regexp
+	label       *regexp.Regexp
+	environment *regexp.Regexp
+	paths       []*regexp.Regexp
+	allPaths    []*regexp.Regexp
+
+	then     string
+	level    *float64
+	template *compiledTemplate
+}
+
+// ruleResult is the outcome of applying the rules to a delivery.
+type ruleResult struct {
+	drop     bool
+	send     bool
+	rule     int
+	level    *float64
+	template *compiledTemplate
+}
+
+func compileRules(configs []RuleConfig) ([]rule, error) {
+	var rules []rule
+	for i, config := range configs {
+		r := rule{
+			then:  config.Then,
+			level: config.Level,
+		}
+		switch r.then {
+		case "", "drop", "send":
+		default:
+			return nil, fmt.Errorf("Invalid then %q in rule %d, expected drop or send", r.then, i+1)
+		}
+
+		var err error
+		patterns := []struct {
+			dest    **regexp.Regexp
+			pattern string
+		}{
+			{&r.repository, config.Repository},
+			{&r.event, config.Event},
+			{&r.action, config.Action},
+			{&r.sender, config.Sender},
+			{&r.branch, config.Branch},
+			{&r.label, config.Label},
+			{&r.environment, config.Environment},
+		}
+		for _, p := range patterns {
+			if p.pattern == "" {
+				continue
+			}
+			if *p.dest, err = compileGlob(p.pattern); err != nil {
+				return nil, fmt.Errorf("Invalid pattern %q in rule %d: %s", p.pattern, i+1, err)
+			}
+		}
+		if r.paths, err = compileGlobs(config.Paths); err != nil {
+			return nil, fmt.Errorf("Invalid pattern in rule %d: %s", i+1, err)
+		}
+		if r.allPaths, err = compileGlobs(config.AllPaths); err != nil {
+			return nil, fmt.Errorf("Invalid pattern in rule %d: %s", i+1, err)
+		}
+
+		if config.Template != nil {
+			name := fmt.Sprintf("rule %d", i+1)
+			set, err := compileTemplates(map[string]messageTemplate{name: *config.Template})
+			if err != nil {
+				return nil, err
+			}
+			compiled := set[name]
+			r.template = &compiled
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+// compileGlob turns a glob into a regexp matching the whole string.
+func compileGlob(glob string) (*regexp.Regexp, error) {
+	var expr bytes.Buffer
+	expr.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				expr.WriteString(".*")
+				i++
+			} else {
+				expr.WriteString("[^/]*")
+			}
+		case '?':
+			expr.WriteString("[^/]")
+		default:
+			expr.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	expr.WriteString("$")
+	return regexp.Compile(expr.String())
+}
+
+func compileGlobs(globs []string) ([]*regexp.Regexp, error) {
+	var res []*regexp.Regexp
+	for _, glob := range globs {
+		re, err := compileGlob(glob)
+		if err != nil {
+			return nil, fmt.Errorf("%q: %s", glob, err)
+		}
+		res = append(res, re)
+	}
+	return res, nil
+}
+
+// matchesGlobs reports whether value matches one of res.
+func matchesGlobs(res []*regexp.Regexp, value string) bool {
+	for _, re := range res {
+		if re.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// anyPath reports whether one of paths matches res.
+func anyPath(res []*regexp.Regexp, paths []string) bool {
+	for _, path := range paths {
+		if matchesGlobs(res, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// allPaths reports whether every one of paths matches res, and there is at
+// least one.
+func allPaths(res []*regexp.Regexp, paths []string) bool {
+	for _, path := range paths {
+		if !matchesGlobs(res, path) {
+			return false
+		}
+	}
+	return len(paths) > 0
+}
+
+func matchAny(re *regexp.Regexp, values ...string) bool {
+	for _, value := range values {
+		if re.MatchString(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r rule) matches(meta *Metadata) bool {
+	switch {
+	case r.repository != nil && !r.repository.MatchString(meta.Repository):
+		return false
+	case r.event != nil && !r.event.MatchString(meta.Event):
+		return false
+	case r.action != nil && !r.action.MatchString(meta.Action):
+		return false
+	case r.sender != nil && !r.sender.MatchString(meta.Sender):
+		return false
+	case r.branch != nil && !r.branch.MatchString(meta.Ref):
+		return false
+	case r.label != nil && !matchAny(r.label, meta.Labels...):
+		return false
+	case r.environment != nil && !r.environment.MatchString(meta.Environment):
+		return false
+	case r.paths != nil && !anyPath(r.paths, meta.Paths):
+		return false
+	case r.allPaths != nil && !allPaths(r.allPaths, meta.Paths):
+		return false
+	}
+	return true
+}
+
+// applyRules runs a delivery through the rules in order, collecting the
+// level and template to use until a rule decides to drop or send it.
+func applyRules(rules []rule, meta *Metadata) ruleResult {
+	var result ruleResult
+	for i, r := range rules {
+		if !r.matches(meta) {
+			continue
+		}
+		result.rule = i + 1
+		if r.level != nil {
+			result.level = r.level
+		}
+		if r.template != nil {
+			result.template = r.template
+		}
+		if r.then != "" {
+			result.drop = r.then == "drop"
+			result.send = r.then == "send"
+			break
+		}
+	}
+	return result
+}
+
+// apply overrides the notification with the outcome of the rules.
+func (result ruleResult) apply(note *pmb.Notification, meta *Metadata) error {
+	if result.level != nil {
+		note.Level = *result.level
+	}
+	if result.template == nil {
+		return nil
+	}
+	if meta.Data == nil {
+		return fmt.Errorf("Template from rule %d can't be used for %s events", result.rule, meta.Event)
+	}
+	return result.template.render(note, meta.Data)
+}
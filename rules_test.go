@@ -0,0 +1,118 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/justone/pmb/api"
+)
+
+func testRules(t *testing.T, configs ...RuleConfig) []rule {
+	rules, err := compileRules(configs)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	return rules
+}
+
+func TestCompileGlob(t *testing.T) {
+	re, _ := compileGlob("dependabot/*")
+	assert(t, re.MatchString("dependabot/npm"), "single segment not matched")
+	assert(t, !re.MatchString("dependabot/npm_and_yarn/lodash-4.17.21"), "* matched across segments")
+
+	re, _ = compileGlob("dependabot/**")
+	assert(t, re.MatchString("dependabot/npm_and_yarn/lodash-4.17.21"), "** not matched across segments")
+
+	re, _ = compileGlob("org/release-?.x")
+	assert(t, re.MatchString("org/release-1.x") && !re.MatchString("org/release-10.x"), "? incorrect")
+}
+
+func TestRulesDropBranch(t *testing.T) {
+	rules := testRules(t, RuleConfig{Event: "push", Branch: "dependabot/**", Then: "drop"})
+
+	result := applyRules(rules, &Metadata{Event: "push", Ref: "dependabot/npm_and_yarn/lodash-4.17.21"})
+	assert(t, result.drop && result.rule == 1, "dependabot push not dropped")
+
+	result = applyRules(rules, &Metadata{Event: "push", Ref: "master"})
+	assert(t, !result.drop, "master push dropped")
+}
+
+func TestRulesFirstDecisionWins(t *testing.T) {
+	rules := testRules(t,
+		RuleConfig{Repository: "org/release-*", Event: "pull_request", Action: "closed", Then: "send"},
+		RuleConfig{Repository: "org/release-*", Event: "pull_request", Then: "drop"},
+	)
+
+	result := applyRules(rules, &Metadata{Event: "pull_request", Action: "closed", Repository: "org/release-tools"})
+	assert(t, !result.drop, "closed pull request dropped")
+
+	result = applyRules(rules, &Metadata{Event: "pull_request", Action: "opened", Repository: "org/release-tools"})
+	assert(t, result.drop, "opened pull request sent")
+
+	result = applyRules(rules, &Metadata{Event: "pull_request", Action: "opened", Repository: "org/website"})
+	assert(t, !result.drop, "other repository dropped")
+}
+
+func TestRulesLevelAndTemplate(t *testing.T) {
+	level := 7.0
+	rules := testRules(t,
+		RuleConfig{Label: "urgent", Level: &level},
+		RuleConfig{Event: "issues", Template: &messageTemplate{Message: "URGENT: {{.Issue.Title}}"}},
+	)
+
+	meta := &Metadata{Event: "issues", Labels: []string{"bug", "urgent"}, Data: &IssuesEvent{Issue: &Issue{Title: "Spelling error in the README file"}}}
+	result := applyRules(rules, meta)
+	note := &pmb.Notification{Message: "Issue 2", URL: "https://github.com/baxterthehacker/public-repo/issues/2", Level: 4}
+	if err := result.apply(note, meta); err != nil {
+		t.Errorf("Error: %s", err)
+	}
+
+	assert(t, note.Level == 7, "Level incorrect")
+	assert(t, note.Message == "URGENT: Spelling error in the README file", "Message incorrect")
+	assert(t, note.URL == "https://github.com/baxterthehacker/public-repo/issues/2", "URL incorrect")
+}
+
+func TestCompileRulesInvalid(t *testing.T) {
+	_, err := compileRules([]RuleConfig{{Event: "push", Then: "ignore"}})
+	assert(t, err != nil, "invalid then accepted")
+
+	_, err = compileRules([]RuleConfig{{Template: &messageTemplate{Message: "{{.Ref"}}})
+	assert(t, err != nil, "invalid template accepted")
+}
+
+func TestRulesPaths(t *testing.T) {
+	billing := 7.0
+	rules := testRules(t,
+		RuleConfig{Event: "push", AllPaths: []string{"docs/**", "**.md"}, Then: "drop"},
+		RuleConfig{Event: "push", Paths: []string{"services/billing/**"}, Level: &billing},
+	)
+
+	result := applyRules(rules, &Metadata{Event: "push", Paths: []string{"docs/setup.md", "README.md"}})
+	assert(t, result.drop, "docs-only push not dropped")
+
+	result = applyRules(rules, &Metadata{Event: "push", Paths: []string{"README.md", "services/billing/invoice.go"}})
+	assert(t, !result.drop && result.level != nil && *result.level == 7, "billing push level not set")
+
+	result = applyRules(rules, &Metadata{Event: "push", Paths: []string{"services/search/index.go"}})
+	assert(t, !result.drop && result.level == nil, "other push matched")
+
+	result = applyRules(rules, &Metadata{Event: "push"})
+	assert(t, !result.drop && result.level == nil, "push without paths matched")
+}
+
+func TestPushPaths(t *testing.T) {
+	commit := func(id string, added, modified, removed string) string {
+		return fmt.Sprintf(`{"id":"%s","message":"Update","author":{"name":"Codertocat","username":"Codertocat"},"added":[%s],"modified":[%s],"removed":[%s]}`, id, added, modified, removed)
+	}
+	_, meta, err := parseEvent("push", pushJSON("refs/heads/main", false,
+		commit("1111111aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa", `"services/billing/invoice.go"`, `"README.md"`, ""),
+		commit("2222222bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb", "", `"README.md"`, `"services/billing/legacy.go"`),
+	))
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+
+	assert(t, strings.Join(meta.Paths, " ") == "services/billing/invoice.go README.md services/billing/legacy.go", "Paths incorrect")
+}
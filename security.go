@@ -0,0 +1,260 @@
+package main
+
+func init() {
+	RegisterHandler("dependabot_alert", payloadHandler(func() eventPayload { return &DependabotAlertEvent{} }))
+	RegisterHandler("code_scanning_alert", payloadHandler(func() eventPayload { return &CodeScanningAlertEvent{} }))
+	RegisterHandler("secret_scanning_alert", payloadHandler(func() eventPayload { return &SecretScanningAlertEvent{} }))
+	RegisterHandler("repository_vulnerability_alert", payloadHandler(func() eventPayload { return &RepositoryVulnerabilityAlertEvent{} }))
+	RegisterHandler("security_advisory", payloadHandler(func() eventPayload { return &SecurityAdvisoryEvent{} }))
+	registerTemplates(map[string]messageTemplate{
+		"dependabot_alert": {
+			Message: "Dependabot alert {{.Alert.Number}} {{.Action}} on {{.Repository.FullName}}: {{.Severity}}" +
+				" in {{.Alert.Dependency.Package.Ecosystem}} package {{.Alert.Dependency.Package.Name}}" +
+				"{{with .Alert.Dependency.ManifestPath}} ({{.}}){{end}}: {{truncate .Alert.SecurityAdvisory.Summary 60}}",
+			URL: "{{.Alert.HTMLURL}}",
+		},
+		"code_scanning_alert": {
+			Message: "Code scanning alert {{.Alert.Number}} {{.Action}} on {{.Repository.FullName}}: {{.Severity}}" +
+				" {{truncate (or .Alert.Rule.Description .Alert.Rule.ID) 60}}{{with .Alert.Tool.Name}} ({{.}}){{end}}" +
+				"{{with .Alert.MostRecentInstance}}{{with .Location.Path}} in {{.}}{{end}}{{with .Location.StartLine}}:{{.}}{{end}}{{end}}",
+			URL: "{{.Alert.HTMLURL}}",
+		},
+		"secret_scanning_alert": {
+			Message: "Secret scanning alert {{.Alert.Number}} {{.Action}} on {{.Repository.FullName}}:" +
+				" {{or .Alert.SecretTypeDisplayName .Alert.SecretType}}{{with .Alert.Resolution}} ({{.}}){{end}}",
+			URL: "{{.Alert.HTMLURL}}",
+		},
+		"repository_vulnerability_alert": {
+			Message: "Vulnerability alert {{.Action}} on {{.Repository.FullName}}: {{.Severity}} in {{.Alert.AffectedPackageName}}" +
+				" {{.Alert.AffectedRange}} ({{or .Alert.GHSAID .Alert.ExternalIdentifier}}){{with .Alert.FixedIn}}, fixed in {{.}}{{end}}",
+			URL: "{{or .Alert.ExternalReference (printf \"%s/security/dependabot\" .Repository.HTMLURL)}}",
+		},
+		"security_advisory": {
+			Message: "Security advisory {{.SecurityAdvisory.GHSAID}} {{.Action}}: {{.Severity}}" +
+				" {{truncate .SecurityAdvisory.Summary 60}}{{with .Packages}} affecting {{join . \", \"}}{{end}}",
+			URL: "{{.SecurityAdvisory.HTMLURL}}",
+		},
+	})
+}
+
+// severities normalizes the severities used across GitHub's security
+// features to low, medium, high and critical.
+var severities = map[string]string{
+	"low":      "low",
+	"note":     "low",
+	"medium":   "medium",
+	"moderate": "medium",
+	"warning":  "medium",
+	"high":     "high",
+	"error":    "high",
+	"critical": "critical",
+}
+
+// defaultSeverityLevels are the notification levels for each severity,
+// unless configured otherwise.
+var defaultSeverityLevels = map[string]float64{
+	"low":      5,
+	"medium":   6,
+	"high":     7,
+	"critical": 8,
+}
+
+func normalizeSeverity(severity string) string {
+	return severities[severity]
+}
+
+// Package is a package in a software ecosystem.
+type Package struct {
+	Ecosystem string `json:"ecosystem"`
+	Name      string `json:"name" required:"true"`
+}
+
+// AdvisoryVulnerability is a package version range affected by an advisory.
+type AdvisoryVulnerability struct {
+	Package                *Package `json:"package" required:"true"`
+	Severity               string   `json:"severity"`
+	VulnerableVersionRange string   `json:"vulnerable_version_range"`
+	FirstPatchedVersion    *struct {
+		Identifier string `json:"identifier"`
+	} `json:"first_patched_version"`
+}
+
+// SecurityAdvisory is a GitHub security advisory.
+type SecurityAdvisory struct {
+	GHSAID          string                  `json:"ghsa_id" required:"true"`
+	CVEID           string                  `json:"cve_id"`
+	Summary         string                  `json:"summary" required:"true"`
+	Severity        string                  `json:"severity"`
+	HTMLURL         string                  `json:"html_url"`
+	Vulnerabilities []AdvisoryVulnerability `json:"vulnerabilities"`
+}
+
+// DependabotAlert is an alert about a vulnerable dependency.
+type DependabotAlert struct {
+	Number     int    `json:"number" required:"true"`
+	State      string `json:"state"`
+	HTMLURL    string `json:"html_url" required:"true"`
+	Dependency struct {
+		Package      *Package `json:"package" required:"true"`
+		ManifestPath string   `json:"manifest_path"`
+		Scope        string   `json:"scope"`
+	} `json:"dependency"`
+	SecurityAdvisory      *SecurityAdvisory      `json:"security_advisory" required:"true"`
+	SecurityVulnerability *AdvisoryVulnerability `json:"security_vulnerability"`
+}
+
+// DependabotAlertEvent is the payload of dependabot_alert events.
+type DependabotAlertEvent struct {
+	Event
+	Alert *DependabotAlert `json:"alert" required:"true"`
+}
+
+// Severity is the normalized severity of the vulnerability.
+func (e *DependabotAlertEvent) Severity() string {
+	if v := e.Alert.SecurityVulnerability; v != nil && v.Severity != "" {
+		return normalizeSeverity(v.Severity)
+	}
+	return normalizeSeverity(e.Alert.SecurityAdvisory.Severity)
+}
+
+func (e *DependabotAlertEvent) describe(meta *Metadata) {
+	meta.State = e.Alert.State
+	meta.Severity = e.Severity()
+}
+
+// CodeScanningAlert is an alert raised by a code scanning tool.
+type CodeScanningAlert struct {
+	Number  int    `json:"number" required:"true"`
+	State   string `json:"state"`
+	HTMLURL string `json:"html_url" required:"true"`
+	Rule    struct {
+		ID                    string `json:"id" required:"true"`
+		Name                  string `json:"name"`
+		Description           string `json:"description"`
+		Severity              string `json:"severity"`
+		SecuritySeverityLevel string `json:"security_severity_level"`
+	} `json:"rule"`
+	Tool struct {
+		Name string `json:"name"`
+	} `json:"tool"`
+	MostRecentInstance *struct {
+		Ref      string `json:"ref"`
+		Location struct {
+			Path      string `json:"path"`
+			StartLine int    `json:"start_line"`
+		} `json:"location"`
+	} `json:"most_recent_instance"`
+}
+
+// CodeScanningAlertEvent is the payload of code_scanning_alert events.
+type CodeScanningAlertEvent struct {
+	Event
+	Ref   string             `json:"ref"`
+	Alert *CodeScanningAlert `json:"alert" required:"true"`
+}
+
+// Severity is the normalized severity of the alert, preferring the
+// security severity of the rule over its general severity.
+func (e *CodeScanningAlertEvent) Severity() string {
+	if e.Alert.Rule.SecuritySeverityLevel != "" {
+		return normalizeSeverity(e.Alert.Rule.SecuritySeverityLevel)
+	}
+	return normalizeSeverity(e.Alert.Rule.Severity)
+}
+
+func (e *CodeScanningAlertEvent) describe(meta *Metadata) {
+	meta.Ref = branch(e.Ref)
+	meta.State = e.Alert.State
+	meta.Severity = e.Severity()
+}
+
+// SecretScanningAlert is an alert about a secret committed to a repository.
+type SecretScanningAlert struct {
+	Number                int    `json:"number" required:"true"`
+	State                 string `json:"state"`
+	Resolution            string `json:"resolution"`
+	HTMLURL               string `json:"html_url" required:"true"`
+	SecretType            string `json:"secret_type"`
+	SecretTypeDisplayName string `json:"secret_type_display_name"`
+}
+
+// SecretScanningAlertEvent is the payload of secret_scanning_alert events.
+type SecretScanningAlertEvent struct {
+	Event
+	Alert *SecretScanningAlert `json:"alert" required:"true"`
+}
+
+func (e *SecretScanningAlertEvent) describe(meta *Metadata) {
+	meta.State = e.Alert.State
+	// A leaked secret is critical until it has been dealt with.
+	if e.Alert.State == "open" {
+		meta.Severity = "critical"
+	}
+}
+
+// RepositoryVulnerabilityAlert is the alert sent before Dependabot alerts
+// replaced it.
+type RepositoryVulnerabilityAlert struct {
+	AffectedPackageName string `json:"affected_package_name" required:"true"`
+	AffectedRange       string `json:"affected_range"`
+	ExternalIdentifier  string `json:"external_identifier"`
+	ExternalReference   string `json:"external_reference"`
+	GHSAID              string `json:"ghsa_id"`
+	FixedIn             string `json:"fixed_in"`
+	Severity            string `json:"severity"`
+	State               string `json:"state"`
+}
+
+// RepositoryVulnerabilityAlertEvent is the payload of
+// repository_vulnerability_alert events.
+type RepositoryVulnerabilityAlertEvent struct {
+	Event
+	Alert *RepositoryVulnerabilityAlert `json:"alert" required:"true"`
+}
+
+// Severity is the normalized severity of the vulnerability.
+func (e *RepositoryVulnerabilityAlertEvent) Severity() string {
+	return normalizeSeverity(e.Alert.Severity)
+}
+
+func (e *RepositoryVulnerabilityAlertEvent) describe(meta *Metadata) {
+	meta.State = e.Alert.State
+	meta.Severity = e.Severity()
+}
+
+// SecurityAdvisoryEvent is the payload of security_advisory events. These
+// are about advisories in the GitHub Advisory Database rather than a
+// repository, so unlike other events the repository and sender are
+// optional.
+type SecurityAdvisoryEvent struct {
+	Action           string            `json:"action"`
+	Repository       *Repository       `json:"repository"`
+	Sender           *User             `json:"sender"`
+	SecurityAdvisory *SecurityAdvisory `json:"security_advisory" required:"true"`
+}
+
+func (e *SecurityAdvisoryEvent) common() Event {
+	return Event{Action: e.Action, Repository: e.Repository, Sender: e.Sender}
+}
+
+// Severity is the normalized severity of the advisory.
+func (e *SecurityAdvisoryEvent) Severity() string {
+	return normalizeSeverity(e.SecurityAdvisory.Severity)
+}
+
+// Packages lists the affected packages, once each.
+func (e *SecurityAdvisoryEvent) Packages() []string {
+	var packages []string
+	seen := make(map[string]bool)
+	for _, v := range e.SecurityAdvisory.Vulnerabilities {
+		if !seen[v.Package.Name] {
+			seen[v.Package.Name] = true
+			packages = append(packages, v.Package.Name)
+		}
+	}
+	return packages
+}
+
+func (e *SecurityAdvisoryEvent) describe(meta *Metadata) {
+	meta.Severity = e.Severity()
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const dependabotAlertJSON = `{"action":"created","alert":{"number":2,"state":"open","dependency":{"package":{"ecosystem":"npm","name":"lodash"},"manifest_path":"package-lock.json","scope":"runtime"},"security_advisory":{"ghsa_id":"GHSA-jf85-cpcp-j695","cve_id":"CVE-2019-10744","summary":"Prototype Pollution in lodash","description":"Versions of lodash before 4.17.12 are vulnerable to Prototype Pollution.","vulnerabilities":[{"package":{"ecosystem":"npm","name":"lodash"},"severity":"critical","vulnerable_version_range":"< 4.17.12","first_patched_version":{"identifier":"4.17.12"}}],"severity":"critical","cvss":{"vector_string":"CVSS:3.1/AV:N/AC:L/PR:N/UI:N/S:U/C:H/I:H/A:H","score":9.1},"cwes":[{"cwe_id":"CWE-400","name":"Uncontrolled Resource Consumption"}],"identifiers":[{"type":"GHSA","value":"GHSA-jf85-cpcp-j695"},{"type":"CVE","value":"CVE-2019-10744"}],"references":[{"url":"https://nvd.nist.gov/vuln/detail/CVE-2019-10744"}],"published_at":"2019-07-10T19:45:23Z","updated_at":"2023-01-18T17:18:07Z","withdrawn_at":null},"security_vulnerability":{"package":{"ecosystem":"npm","name":"lodash"},"severity":"critical","vulnerable_version_range":"< 4.17.12","first_patched_version":{"identifier":"4.17.12"}},"url":"https://api.github.com/repos/octocat/hello-world/dependabot/alerts/2","html_url":"https://github.com/octocat/hello-world/security/dependabot/2","created_at":"2022-06-15T07:43:03Z","updated_at":"2022-06-15T07:43:03Z","dismissed_at":null,"dismissed_by":null,"dismissed_reason":null,"dismissed_comment":null,"fixed_at":null},"repository":{"id":1296269,"node_id":"MDEwOlJlcG9zaXRvcnkxMjk2MjY5","name":"hello-world","full_name":"octocat/hello-world","private":false,"owner":{"login":"octocat","id":1,"type":"User","site_admin":false},"html_url":"https://github.com/octocat/hello-world","fork":false,"url":"https://api.github.com/repos/octocat/hello-world","default_branch":"main"},"sender":{"login":"dependabot[bot]","id":49699333,"node_id":"MDM6Qm90NDk2OTkzMzM=","html_url":"https://github.com/apps/dependabot","type":"Bot","site_admin":false}}`
+
+const codeScanningAlertJSON = `{"action":"created","alert":{"number":4,"created_at":"2020-11-06T21:04:23Z","url":"https://api.github.com/repos/octocat/hello-world/code-scanning/alerts/4","html_url":"https://github.com/octocat/hello-world/security/code-scanning/4","state":"open","fixed_at":null,"dismissed_by":null,"dismissed_at":null,"dismissed_reason":null,"rule":{"id":"js/zipslip","severity":"error","security_severity_level":"high","description":"Arbitrary file write during zip extraction","name":"js/zipslip","tags":["security","external/cwe/cwe-022"]},"tool":{"name":"CodeQL","version":"2.4.0"},"most_recent_instance":{"ref":"refs/heads/main","analysis_key":".github/workflows/codeql-analysis.yml:analyze","environment":"{}","state":"open","commit_sha":"4a0acc2d8e6dec6d1a0cf8db79a8a5b00a5ac0ad","message":{"text":"Unsanitized zip archive entry, which may contain '..', is used in a file system operation."},"location":{"path":"src/unzip.js","start_line":25,"end_line":25,"start_column":21,"end_column":31},"classifications":[]}},"ref":"refs/heads/main","commit_oid":"4a0acc2d8e6dec6d1a0cf8db79a8a5b00a5ac0ad","repository":{"id":1296269,"name":"hello-world","full_name":"octocat/hello-world","private":false,"owner":{"login":"octocat","id":1,"type":"User"},"html_url":"https://github.com/octocat/hello-world"},"sender":{"login":"github","id":9919,"html_url":"https://github.com/github","type":"Organization","site_admin":false}}`
+
+const secretScanningAlertJSON = `{"action":"created","alert":{"number":3,"secret_type":"github_personal_access_token","secret_type_display_name":"GitHub Personal Access Token","validity":"active","resolution":null,"resolved_by":null,"resolved_at":null,"push_protection_bypassed":false,"state":"open","url":"https://api.github.com/repos/octocat/hello-world/secret-scanning/alerts/3","html_url":"https://github.com/octocat/hello-world/security/secret-scanning/3","created_at":"2022-04-25T21:40:08Z"},"repository":{"id":1296269,"name":"hello-world","full_name":"octocat/hello-world","private":false,"owner":{"login":"octocat","id":1,"type":"User"},"html_url":"https://github.com/octocat/hello-world"},"sender":{"login":"github","id":9919,"html_url":"https://github.com/github","type":"Organization","site_admin":false}}`
+
+const securityAdvisoryJSON = `{"action":"published","security_advisory":{"ghsa_id":"GHSA-rf4j-j272-fj86","cve_id":"CVE-2018-6188","summary":"Moderate severity vulnerability that affects django","description":"django.contrib.auth.forms.AuthenticationForm in Django 2.0 before 2.0.2, and 1.11.8 and 1.11.9, allows remote attackers to obtain potentially sensitive information.","severity":"moderate","identifiers":[{"value":"GHSA-rf4j-j272-fj86","type":"GHSA"},{"value":"CVE-2018-6188","type":"CVE"}],"references":[{"url":"https://nvd.nist.gov/vuln/detail/CVE-2018-6188"}],"html_url":"https://github.com/advisories/GHSA-rf4j-j272-fj86","published_at":"2018-10-03T21:13:54Z","updated_at":"2018-10-03T21:13:54Z","withdrawn_at":null,"vulnerabilities":[{"package":{"ecosystem":"pip","name":"django"},"severity":"moderate","vulnerable_version_range":">= 2.0.0, < 2.0.2","first_patched_version":{"identifier":"2.0.2"}},{"package":{"ecosystem":"pip","name":"django"},"severity":"moderate","vulnerable_version_range":">= 1.11.8, < 1.11.10","first_patched_version":{"identifier":"1.11.10"}}]}}`
+
+const repositoryVulnerabilityAlertJSON = `{"action":"create","alert":{"id":91095730,"affected_range":">= 2.0.0, < 2.0.2","affected_package_name":"django","external_reference":"https://nvd.nist.gov/vuln/detail/CVE-2018-6188","external_identifier":"CVE-2018-6188","fixed_in":"2.0.2","ghsa_id":"GHSA-rf4j-j272-fj86","severity":"moderate","state":"open","created_at":"2018-10-03T21:13:54Z"},"repository":{"id":1296269,"name":"hello-world","full_name":"octocat/hello-world","private":false,"owner":{"login":"octocat","id":1,"type":"User"},"html_url":"https://github.com/octocat/hello-world"},"sender":{"login":"github","id":9919,"html_url":"https://github.com/github","type":"Organization","site_admin":false}}`
+
+func TestDependabotAlert(t *testing.T) {
+	name := "dependabot_alert"
+	note, meta, err := parseEvent(name, dependabotAlertJSON)
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+
+	assert(t, note.Message == "Dependabot alert 2 created on octocat/hello-world: critical in npm package lodash (package-lock.json): Prototype Pollution in lodash", "Message incorrect")
+	assert(t, note.URL == "https://github.com/octocat/hello-world/security/dependabot/2", "URL incorrect")
+	assert(t, meta.Severity == "critical", "Severity incorrect")
+}
+
+func TestCodeScanningAlert(t *testing.T) {
+	name := "code_scanning_alert"
+	note, meta, err := parseEvent(name, codeScanningAlertJSON)
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+
+	assert(t, note.Message == "Code scanning alert 4 created on octocat/hello-world: high Arbitrary file write during zip extraction (CodeQL) in src/unzip.js:25", "Message incorrect")
+	assert(t, note.URL == "https://github.com/octocat/hello-world/security/code-scanning/4", "URL incorrect")
+	assert(t, meta.Severity == "high" && meta.Ref == "main", "Metadata incorrect")
+
+	_, meta, err = parseEvent(name, strings.Replace(codeScanningAlertJSON, `"security_severity_level":"high",`, "", 1))
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+	assert(t, meta.Severity == "high", "Rule severity not mapped")
+}
+
+func TestSecretScanningAlert(t *testing.T) {
+	name := "secret_scanning_alert"
+	note, meta, err := parseEvent(name, secretScanningAlertJSON)
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+
+	assert(t, note.Message == "Secret scanning alert 3 created on octocat/hello-world: GitHub Personal Access Token", "Message incorrect")
+	assert(t, note.URL == "https://github.com/octocat/hello-world/security/secret-scanning/3", "URL incorrect")
+	assert(t, meta.Severity == "critical", "Severity incorrect")
+
+	resolved := strings.Replace(secretScanningAlertJSON, `"action":"created"`, `"action":"resolved"`, 1)
+	resolved = strings.Replace(resolved, `"resolution":null`, `"resolution":"revoked"`, 1)
+	resolved = strings.Replace(resolved, `"state":"open"`, `"state":"resolved"`, 1)
+	note, meta, err = parseEvent(name, resolved)
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+	assert(t, note.Message == "Secret scanning alert 3 resolved on octocat/hello-world: GitHub Personal Access Token (revoked)", "Resolved message incorrect")
+	assert(t, meta.Severity == "", "Resolved alert has severity")
+}
+
+func TestSecurityAdvisory(t *testing.T) {
+	name := "security_advisory"
+	note, meta, err := parseEvent(name, securityAdvisoryJSON)
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+
+	assert(t, note.Message == "Security advisory GHSA-rf4j-j272-fj86 published: medium Moderate severity vulnerability that affects django affecting django", "Message incorrect")
+	assert(t, note.URL == "https://github.com/advisories/GHSA-rf4j-j272-fj86", "URL incorrect")
+	assert(t, meta.Severity == "medium" && meta.Repository == "", "Metadata incorrect")
+}
+
+func TestRepositoryVulnerabilityAlert(t *testing.T) {
+	name := "repository_vulnerability_alert"
+	note, meta, err := parseEvent(name, repositoryVulnerabilityAlertJSON)
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+
+	assert(t, note.Message == "Vulnerability alert create on octocat/hello-world: medium in django >= 2.0.0, < 2.0.2 (GHSA-rf4j-j272-fj86), fixed in 2.0.2", "Message incorrect")
+	assert(t, note.URL == "https://nvd.nist.gov/vuln/detail/CVE-2018-6188", "URL incorrect")
+	assert(t, meta.Severity == "medium", "Severity incorrect")
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/justone/pmb/api"
+)
+
+// maxPayloadSize is the largest webhook payload GitHub sends.
+const maxPayloadSize = 25 << 20
+
+// server receives webhook deliveries and forwards them to PMB.
+type server struct {
+	deliveries *deliveryCache
+	outbox     *outbox
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var ip string
+	if realIP, ok := r.Header["X-Real-Ip"]; ok {
+		ip = realIP[0]
+	} else {
+		ip = r.RemoteAddr
+	}
+	logrus.Infof(strings.Join([]string{r.RequestURI, ip, r.Method, fmt.Sprintf("%s", r.Header)}, " "))
+
+	// The body is read before the signature can be checked, so it is
+	// limited to the largest payload GitHub sends.
+	if r.ContentLength > maxPayloadSize {
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxPayloadSize))
+	if err != nil && len(body) >= maxPayloadSize {
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		return
+	} else if err != nil {
+		http.Error(w, "Unable to read request body: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	config := currentSettings()
+
+	if len(config.secrets) > 0 {
+		secret, err := verifySignature(config.secrets, r.Header, body, time.Now())
+		if err != nil {
+			logrus.Warnf("Rejected delivery from %s: %s", ip, err)
+			http.Error(w, "Invalid signature", http.StatusUnauthorized)
+			return
+		}
+		logrus.Infof("Delivery %s verified with secret %s", r.Header.Get("X-GitHub-Delivery"), secret.Label)
+	}
+
+	delivery := r.Header.Get("X-GitHub-Delivery")
+	if delivery != "" {
+		if !s.deliveries.Reserve(delivery, time.Now()) {
+			logrus.Infof("Skipping duplicate delivery %s", delivery)
+			return
+		}
+		// Releasing a recorded delivery does nothing.
+		defer s.deliveries.Release(delivery)
+	}
+
+	var eventName string
+	if eventHeaders, ok := r.Header["X-Github-Event"]; ok {
+		eventName = eventHeaders[0]
+	} else {
+		logrus.Warnf("Github event name not found")
+		return
+	}
+
+	eventJSON := string(body)
+
+	notification, meta, err := parseEvent(eventName, eventJSON)
+	if err != nil {
+		logrus.Warnf("Unable to parse event %s: %s, body: %s", eventName, err, eventJSON)
+		return
+	}
+
+	if err := s.deliver(notification, meta); err != nil {
+		logrus.Warnf("%s", err)
+		http.Error(w, "Unable to queue notification", http.StatusInternalServerError)
+		return
+	}
+	if delivery != "" {
+		s.deliveries.Record(delivery, time.Now())
+	}
+}
+
+// deliver runs a notification through the ignore list, rules, bot handling
+// and levels, and queues it unless it was filtered out.
+func (s *server) deliver(notification *pmb.Notification, meta *Metadata) error {
+	config := currentSettings()
+
+	if _, ok := config.ignore[meta.Sender]; ok {
+		logrus.Warnf(fmt.Sprintf("ignoring notification from %s", meta.Sender))
+		return nil
+	}
+
+	result := applyRules(config.rules, meta)
+	if result.drop {
+		logrus.Infof("Dropping %s event for %s by rule %d", meta.Event, meta.Repository, result.rule)
+		return nil
+	}
+
+	// A rule that sends the delivery overrides the handling of bots.
+	mode := botNotify
+	if !result.send {
+		mode = botMode(config, meta)
+	}
+	if mode == botIgnore {
+		logrus.Infof("Ignoring %s event for %s from bot %s", meta.Event, meta.Repository, meta.Sender)
+		return nil
+	}
+
+	if notification == nil {
+		logrus.Warnf("skipping notification")
+		return nil
+	}
+
+	fallback := config.level
+	if level, ok := config.severityLevels[meta.Severity]; ok {
+		fallback = level
+	}
+	notification.Level = resolveLevel(config.levels, meta, fallback)
+	if level, ok := resolveEnvironmentLevel(config.envLevels, meta); ok {
+		notification.Level = level
+	}
+	if mode == botLower && config.botLevel < notification.Level {
+		notification.Level = config.botLevel
+	}
+	mentioned := config.mentions.match(meta.Data)
+	if mentioned && config.mentionLevel > notification.Level {
+		notification.Level = config.mentionLevel
+	}
+	if err := result.apply(notification, meta); err != nil {
+		logrus.Warnf("Unable to apply rule %d: %s", result.rule, err)
+	}
+	if mentioned {
+		notification.Message = mentionPrefix + notification.Message
+	}
+
+	logrus.Infof("Queueing notification: %v", notification)
+	return s.outbox.Enqueue(*notification)
+}
+
+// statusHandler reports the state of the PMB connection and the outbox as
+// JSON.
+func statusHandler(conn *supervisor, queue *outbox) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := struct {
+			PMB    connectionStatus `json:"pmb"`
+			Outbox outboxStatus     `json:"outbox"`
+		}{conn.Status(), queue.Status()}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(status); err != nil {
+			logrus.Warnf("Unable to write status: %s", err)
+		}
+	})
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/justone/pmb-gh/webhook"
+	"github.com/justone/pmb/api"
+)
+
+// testServer returns a server whose outbox is never sent, so that tests can
+// inspect what was queued.
+func testServer(t *testing.T) *server {
+	queue, err := openOutbox("", func(note pmb.Notification) error { return nil }, 0, 0)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	return &server{outbox: queue}
+}
+
+// queued returns the notifications waiting in the outbox of s.
+func queued(s *server) []pmb.Notification {
+	var notes []pmb.Notification
+	for _, entry := range s.outbox.pending {
+		notes = append(notes, entry.Notification)
+	}
+	return notes
+}
+
+func TestDeliverSeverityLevel(t *testing.T) {
+	opts.Level = 4
+	defer func() { opts.Level = 0 }()
+	defer withDefaultSettings(t)()
+	s := testServer(t)
+
+	for _, payload := range []struct{ name, json string }{
+		{"dependabot_alert", dependabotAlertJSON},
+		{"code_scanning_alert", codeScanningAlertJSON},
+		{"release", releaseJSON},
+	} {
+		note, meta, err := parseEvent(payload.name, payload.json)
+		if err != nil {
+			t.Fatalf("Error: %s", err)
+		}
+		if err := s.deliver(note, meta); err != nil {
+			t.Errorf("Error: %s", err)
+		}
+	}
+
+	notes := queued(s)
+	assert(t, len(notes) == 3, "notifications not queued")
+	assert(t, notes[0].Level == 8, "critical level incorrect")
+	assert(t, notes[1].Level == 7, "high level incorrect")
+	assert(t, notes[2].Level == 4, "default level incorrect")
+}
+
+func TestDeliverSeverityLevelOverridden(t *testing.T) {
+	opts.SeverityLevels = []string{"critical=9"}
+	opts.Levels = []string{"dependabot_alert@octocat/*=3"}
+	defer func() { opts.SeverityLevels, opts.Levels = nil, nil }()
+	defer withDefaultSettings(t)()
+	s := testServer(t)
+
+	note, meta, err := parseEvent("dependabot_alert", dependabotAlertJSON)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	s.deliver(note, meta)
+	assert(t, queued(s)[0].Level == 3, "event level did not override severity")
+	assert(t, currentSettings().severityLevels["critical"] == 9, "severity level not configured")
+}
+
+func TestDeliverMention(t *testing.T) {
+	opts.Level = 4
+	opts.Mentions = []string{"octocat"}
+	opts.MentionLevel = 6
+	defer func() { opts.Level, opts.Mentions, opts.MentionLevel = 0, nil, 0 }()
+	defer withDefaultSettings(t)()
+	s := testServer(t)
+
+	for _, action := range []string{"assigned", "labeled"} {
+		note, meta, err := parseEvent("issues", fmt.Sprintf(issueActionJSON, action, `"assignee":{"login":"octocat","id":1,"type":"User"},`))
+		if err != nil {
+			t.Fatalf("Error: %s", err)
+		}
+		if err := s.deliver(note, meta); err != nil {
+			t.Errorf("Error: %s", err)
+		}
+	}
+
+	notes := queued(s)
+	assert(t, len(notes) == 2, "notifications not queued")
+	assert(t, notes[0].Level == 6, "mention level incorrect")
+	assert(t, strings.HasPrefix(notes[0].Message, "You were mentioned: Issue 1"), "Message incorrect: "+notes[0].Message)
+	assert(t, notes[1].Level == 4, "level raised without mention")
+	assert(t, !strings.HasPrefix(notes[1].Message, "You were mentioned"), "Message incorrect: "+notes[1].Message)
+}
+
+func TestDeliverBots(t *testing.T) {
+	opts.Level = 4
+	opts.Bots = botLower
+	opts.BotLevel = 2
+	opts.BotEvents = []string{"pull_request:closed=ignore"}
+	defer func() { opts.Level, opts.Bots, opts.BotLevel, opts.BotEvents = 0, "", 0, nil }()
+	defer withDefaultSettings(t)()
+	s := testServer(t)
+
+	json := strings.Replace(pullRequestActionJSON, `"sender":{"login":"Codertocat","id":21031067,"html_url":"https://github.com/Codertocat","type":"User"}`,
+		`"sender":{"login":"dependabot[bot]","id":49699333,"html_url":"https://github.com/apps/dependabot","type":"Bot"}`, 1)
+	for _, action := range []string{"opened", "closed"} {
+		note, meta, err := parseEvent("pull_request", fmt.Sprintf(json, action, ""))
+		if err != nil {
+			t.Fatalf("Error: %s", err)
+		}
+		if err := s.deliver(note, meta); err != nil {
+			t.Errorf("Error: %s", err)
+		}
+	}
+
+	notes := queued(s)
+	assert(t, len(notes) == 1, "ignored bot event queued")
+	assert(t, notes[0].Level == 2, "bot level incorrect")
+
+	current.Store(&settings{
+		level:     4,
+		botMode:   botIgnore,
+		botEvents: map[string]string{},
+		rules:     testRules(t, RuleConfig{Sender: "dependabot[bot]", Then: "send"}),
+	})
+	note, meta, err := parseEvent("pull_request", fmt.Sprintf(json, "closed", ""))
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	s.deliver(note, meta)
+	assert(t, len(queued(s)) == 2, "bot event sent by rule not queued")
+}
+
+// post sends a delivery of event to s, signed with secret unless it is
+// empty, and returns the response.
+func post(s *server, event, delivery, secret, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+	r.Header.Set("X-GitHub-Event", event)
+	if delivery != "" {
+		r.Header.Set("X-GitHub-Delivery", delivery)
+	}
+	if secret != "" {
+		r.Header.Set("X-Hub-Signature-256", "sha256="+sign(sha256.New, secret, body))
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+// countCalls registers a handler for event that counts how often it is
+// called, returning a function that unregisters it.
+func countCalls(event string, calls *int) func() {
+	RegisterHandler(event, EventHandlerFunc(func(name string, payload []byte) (*pmb.Notification, *Metadata, error) {
+		*calls++
+		return &pmb.Notification{Message: "Audit finished"}, nil, nil
+	}))
+	return func() { webhook.Unregister(event) }
+}
+
+func TestServeHTTPSignature(t *testing.T) {
+	opts.Secrets = []string{"s3cret"}
+	defer func() { opts.Secrets = nil }()
+	defer withDefaultSettings(t)()
+	calls := 0
+	defer countCalls("internal_audit", &calls)()
+	s := testServer(t)
+
+	w := post(s, "internal_audit", "", "", `{}`)
+	assert(t, w.Code == http.StatusUnauthorized, fmt.Sprintf("unsigned delivery got %d", w.Code))
+	w = post(s, "internal_audit", "", "other", `{}`)
+	assert(t, w.Code == http.StatusUnauthorized, fmt.Sprintf("badly signed delivery got %d", w.Code))
+	assert(t, calls == 0 && len(queued(s)) == 0, "rejected delivery handled")
+
+	w = post(s, "internal_audit", "", "s3cret", `{}`)
+	assert(t, w.Code == http.StatusOK, fmt.Sprintf("signed delivery got %d", w.Code))
+	assert(t, calls == 1 && len(queued(s)) == 1, "signed delivery not queued")
+}
+
+func TestServeHTTPTooLarge(t *testing.T) {
+	opts.Secrets = []string{"s3cret"}
+	defer func() { opts.Secrets = nil }()
+	defer withDefaultSettings(t)()
+	calls := 0
+	defer countCalls("internal_audit", &calls)()
+	s := testServer(t)
+
+	body := `{"zen":"` + strings.Repeat("a", maxPayloadSize) + `"}`
+	w := post(s, "internal_audit", "", "s3cret", body)
+	assert(t, w.Code == http.StatusRequestEntityTooLarge, fmt.Sprintf("oversized delivery got %d", w.Code))
+
+	// Without a Content-Length the body is cut off while reading.
+	r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+	r.Header.Set("X-GitHub-Event", "internal_audit")
+	r.ContentLength = -1
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	assert(t, w.Code == http.StatusRequestEntityTooLarge, fmt.Sprintf("oversized chunked delivery got %d", w.Code))
+	assert(t, calls == 0 && len(queued(s)) == 0, "oversized delivery handled")
+}
+
+func TestServeHTTPDuplicate(t *testing.T) {
+	defer withDefaultSettings(t)()
+	calls := 0
+	defer countCalls("internal_audit", &calls)()
+	s := testServer(t)
+	var err error
+	s.deliveries, err = newDeliveryCache(time.Hour, 10, "")
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+
+	for i := 0; i < 2; i++ {
+		w := post(s, "internal_audit", "72d3162e-cc78-11e3-81ab-4c9367dc0958", "", `{}`)
+		assert(t, w.Code == http.StatusOK, fmt.Sprintf("delivery %d got %d", i+1, w.Code))
+	}
+	assert(t, calls == 1 && len(queued(s)) == 1, "redelivery queued again")
+}
+
+func TestServeHTTPConcurrentDuplicate(t *testing.T) {
+	defer withDefaultSettings(t)()
+	calls := make(chan struct{}, 2)
+	release := make(chan struct{})
+	RegisterHandler("slow_audit", EventHandlerFunc(func(name string, payload []byte) (*pmb.Notification, *Metadata, error) {
+		calls <- struct{}{}
+		<-release
+		return &pmb.Notification{Message: "Audit finished"}, nil, nil
+	}))
+	defer webhook.Unregister("slow_audit")
+	s := testServer(t)
+	var err error
+	s.deliveries, err = newDeliveryCache(time.Hour, 10, "")
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+
+	// GitHub redelivers while the first request is still being handled.
+	first := make(chan int)
+	go func() {
+		first <- post(s, "slow_audit", "72d3162e-cc78-11e3-81ab-4c9367dc0958", "", `{}`).Code
+	}()
+	<-calls
+	w := post(s, "slow_audit", "72d3162e-cc78-11e3-81ab-4c9367dc0958", "", `{}`)
+	assert(t, w.Code == http.StatusOK, fmt.Sprintf("redelivery got %d", w.Code))
+	close(release)
+	assert(t, <-first == http.StatusOK, "first delivery failed")
+
+	assert(t, len(calls) == 0 && len(queued(s)) == 1, "concurrent redelivery handled twice")
+}
+
+func TestServeHTTPQueueFailure(t *testing.T) {
+	defer withDefaultSettings(t)()
+	dir, err := ioutil.TempDir("", "pmb-gh")
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	queue, err := openOutbox(filepath.Join(dir, "outbox"), func(pmb.Notification) error { return nil }, 0, 0)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	// Writes to the outbox fail once its file is closed.
+	queue.Close()
+	deliveries, err := newDeliveryCache(time.Hour, 10, "")
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	s := &server{deliveries: deliveries, outbox: queue}
+
+	w := post(s, "release", "72d3162e-cc78-11e3-81ab-4c9367dc0958", "", releaseJSON)
+	assert(t, w.Code == http.StatusInternalServerError, fmt.Sprintf("failed delivery got %d", w.Code))
+	assert(t, !deliveries.Seen("72d3162e-cc78-11e3-81ab-4c9367dc0958", time.Now()), "failed delivery recorded")
+	assert(t, deliveries.Reserve("72d3162e-cc78-11e3-81ab-4c9367dc0958", time.Now()), "failed delivery still reserved")
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+var (
+	errMissingSignature = errors.New("signature header missing")
+	errBadSignature     = errors.New("signature mismatch")
+)
+
+// webhookSecret is one of the secrets deliveries may be signed with. Several
+// can be active at once so a secret can be rotated across repositories
+// without rejecting deliveries in between.
+type webhookSecret struct {
+	Label   string
+	Value   []byte
+	Expires time.Time
+}
+
+func (s webhookSecret) expired(now time.Time) bool {
+	return !s.Expires.IsZero() && now.After(s.Expires)
+}
+
+// parseSecret parses a secret specification of the form
+// "<secret> [label] [expiry]", where expiry is an RFC 3339 timestamp.
+func parseSecret(spec string, index int) (webhookSecret, error) {
+	fields := strings.Fields(spec)
+	if len(fields) == 0 || len(fields) > 3 {
+		return webhookSecret{}, fmt.Errorf("Invalid secret specification %d", index)
+	}
+
+	secret := webhookSecret{
+		Label: fmt.Sprintf("#%d", index),
+		Value: []byte(fields[0]),
+	}
+	if len(fields) > 1 {
+		secret.Label = fields[1]
+	}
+	if len(fields) > 2 {
+		expires, err := time.Parse(time.RFC3339, fields[2])
+		if err != nil {
+			return webhookSecret{}, fmt.Errorf("Invalid expiry for secret %s: %s", secret.Label, err)
+		}
+		secret.Expires = expires
+	}
+	return secret, nil
+}
+
+// loadSecrets parses the secrets given on the command line or in the
+// environment, followed by those in file, one per line.
+func loadSecrets(specs []string, file string) ([]webhookSecret, error) {
+	if file != "" {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read secret file: %s", err)
+		}
+		defer f.Close()
+
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			line := strings.TrimSpace(scanner.Text())
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			specs = append(specs, line)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("Unable to read secret file: %s", err)
+		}
+	}
+
+	var secrets []webhookSecret
+	for i, spec := range specs {
+		secret, err := parseSecret(spec, i+1)
+		if err != nil {
+			return nil, err
+		}
+		secrets = append(secrets, secret)
+	}
+	return secrets, nil
+}
+
+// verifySignature checks the HMAC GitHub sends with each delivery against
+// every unexpired secret, returning the one that matched. The SHA-256 header
+// is used when present, otherwise the legacy SHA-1 one.
+func verifySignature(secrets []webhookSecret, header http.Header, body []byte, now time.Time) (*webhookSecret, error) {
+	var prefix, signature string
+	var newHash func() hash.Hash
+
+	if sig := header.Get("X-Hub-Signature-256"); sig != "" {
+		prefix, signature, newHash = "sha256=", sig, sha256.New
+	} else if sig := header.Get("X-Hub-Signature"); sig != "" {
+		prefix, signature, newHash = "sha1=", sig, sha1.New
+	} else {
+		return nil, errMissingSignature
+	}
+
+	if !strings.HasPrefix(signature, prefix) {
+		return nil, errBadSignature
+	}
+	expected, err := hex.DecodeString(strings.TrimPrefix(signature, prefix))
+	if err != nil {
+		return nil, errBadSignature
+	}
+
+	var matched *webhookSecret
+	for i := range secrets {
+		if secrets[i].expired(now) {
+			continue
+		}
+		mac := hmac.New(newHash, secrets[i].Value)
+		mac.Write(body)
+		if hmac.Equal(mac.Sum(nil), expected) && matched == nil {
+			matched = &secrets[i]
+		}
+	}
+	if matched == nil {
+		return nil, errBadSignature
+	}
+	return matched, nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func sign(newHash func() hash.Hash, secret, body string) string {
+	mac := hmac.New(newHash, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func testSecrets(t *testing.T, specs ...string) []webhookSecret {
+	secrets, err := loadSecrets(specs, "")
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	return secrets
+}
+
+func TestVerifySignatureSHA256(t *testing.T) {
+	body := `{"zen":"Favor focus over features."}`
+	header := http.Header{}
+	header.Set("X-Hub-Signature-256", "sha256="+sign(sha256.New, "s3cret", body))
+
+	_, err := verifySignature(testSecrets(t, "s3cret"), header, []byte(body), time.Now())
+	assert(t, err == nil, "valid signature rejected")
+	_, err = verifySignature(testSecrets(t, "other"), header, []byte(body), time.Now())
+	assert(t, err == errBadSignature, "wrong secret accepted")
+	_, err = verifySignature(testSecrets(t, "s3cret"), header, []byte(body+" "), time.Now())
+	assert(t, err == errBadSignature, "modified body accepted")
+}
+
+func TestVerifySignatureSHA1(t *testing.T) {
+	body := `{"zen":"Favor focus over features."}`
+	header := http.Header{}
+	header.Set("X-Hub-Signature", "sha1="+sign(sha1.New, "s3cret", body))
+
+	_, err := verifySignature(testSecrets(t, "s3cret"), header, []byte(body), time.Now())
+	assert(t, err == nil, "valid signature rejected")
+	_, err = verifySignature(testSecrets(t, "other"), header, []byte(body), time.Now())
+	assert(t, err == errBadSignature, "wrong secret accepted")
+}
+
+func TestVerifySignatureMissing(t *testing.T) {
+	_, err := verifySignature(testSecrets(t, "s3cret"), http.Header{}, []byte("{}"), time.Now())
+	assert(t, err == errMissingSignature, "missing signature accepted")
+
+	header := http.Header{}
+	header.Set("X-Hub-Signature-256", "sha1=abcd")
+	_, err = verifySignature(testSecrets(t, "s3cret"), header, []byte("{}"), time.Now())
+	assert(t, err == errBadSignature, "wrong prefix accepted")
+}
+
+func TestVerifySignatureRotation(t *testing.T) {
+	body := `{"zen":"Favor focus over features."}`
+	header := http.Header{}
+	header.Set("X-Hub-Signature-256", "sha256="+sign(sha256.New, "old", body))
+
+	secrets := testSecrets(t, "new current", "old previous 2016-11-01T00:00:00Z")
+	assert(t, secrets[0].Label == "current", "label not parsed")
+
+	secret, err := verifySignature(secrets, header, []byte(body), time.Date(2016, 10, 31, 0, 0, 0, 0, time.UTC))
+	assert(t, err == nil, "old secret rejected before expiry")
+	assert(t, secret != nil && secret.Label == "previous", "wrong secret matched")
+
+	_, err = verifySignature(secrets, header, []byte(body), time.Date(2016, 11, 2, 0, 0, 0, 0, time.UTC))
+	assert(t, err == errBadSignature, "expired secret accepted")
+}
+
+func TestParseSecret(t *testing.T) {
+	secret, err := parseSecret("s3cret", 2)
+	assert(t, err == nil, "plain secret rejected")
+	assert(t, secret.Label == "#2", "default label incorrect")
+
+	_, err = parseSecret("s3cret label yesterday", 1)
+	assert(t, err != nil, "invalid expiry accepted")
+	_, err = parseSecret("", 1)
+	assert(t, err != nil, "empty secret accepted")
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/justone/pmb/api"
+)
+
+var errNotConnected = errors.New("not connected to PMB")
+
+// supervisor keeps a connection to PMB open. A connection that fails to
+// send is considered dead, and is replaced by a new one under a fresh
+// client ID, with exponential backoff between attempts. Notifications wait
+// in the outbox in the meantime.
+type supervisor struct {
+	mu        sync.Mutex
+	conn      *pmb.Connection
+	id        string
+	since     time.Time
+	lastError string
+	lost      chan struct{}
+	stuck     int
+
+	// connected is called whenever a new connection has been made.
+	connected func()
+
+	connect     func(id string) (*pmb.Connection, error)
+	send        func(conn *pmb.Connection, note pmb.Notification) error
+	sendTimeout time.Duration
+	minBackoff  time.Duration
+	maxBackoff  time.Duration
+}
+
+func newSupervisor(bus *pmb.PMB, sendTimeout, minBackoff, maxBackoff time.Duration) *supervisor {
+	return &supervisor{
+		lost: make(chan struct{}, 1),
+		connect: func(id string) (*pmb.Connection, error) {
+			return bus.ConnectClient(id, false)
+		},
+		send:        pmb.SendNotification,
+		sendTimeout: sendTimeout,
+		minBackoff:  minBackoff,
+		maxBackoff:  maxBackoff,
+	}
+}
+
+// Run connects to PMB and reconnects whenever the connection is lost, until
+// stop is closed.
+func (s *supervisor) Run(stop <-chan struct{}) {
+	backoff := s.minBackoff
+	for {
+		id := pmb.GenerateRandomID("github")
+		conn, err := s.connect(id)
+		if err != nil {
+			s.mu.Lock()
+			s.lastError = err.Error()
+			s.mu.Unlock()
+
+			logrus.Warnf("Error connecting to PMB, retrying in %s: %s", backoff, err)
+			select {
+			case <-time.After(backoff):
+			case <-stop:
+				return
+			}
+			backoff *= 2
+			if backoff > s.maxBackoff {
+				backoff = s.maxBackoff
+			}
+			continue
+		}
+
+		s.mu.Lock()
+		s.conn = conn
+		s.id = id
+		s.since = time.Now()
+		s.mu.Unlock()
+		logrus.Infof("Connected to PMB as %s", id)
+		backoff = s.minBackoff
+		if s.connected != nil {
+			s.connected()
+		}
+
+		select {
+		case <-s.lost:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Send sends a notification over the current connection, marking the
+// connection dead if that fails.
+//
+// PMB has no way to cancel a send, so one that times out is abandoned and
+// its goroutine stays blocked until PMB gives up on the dead connection, if
+// ever. As the connection is replaced on the first timeout this leaves at
+// most one goroutine per connection; the status endpoint reports how many
+// there are.
+func (s *supervisor) Send(note pmb.Notification) error {
+	s.mu.Lock()
+	conn := s.conn
+	s.mu.Unlock()
+	if conn == nil {
+		return errNotConnected
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		result <- s.send(conn, note)
+	}()
+
+	var err error
+	select {
+	case err = <-result:
+	case <-time.After(s.sendTimeout):
+		err = fmt.Errorf("timed out after %s", s.sendTimeout)
+		s.mu.Lock()
+		s.stuck++
+		s.mu.Unlock()
+		go func() {
+			<-result
+			s.mu.Lock()
+			s.stuck--
+			s.mu.Unlock()
+		}()
+	}
+	if err != nil {
+		s.markDead(conn, err)
+		return err
+	}
+	return nil
+}
+
+func (s *supervisor) markDead(conn *pmb.Connection, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Another send may already have reported this connection.
+	if s.conn != conn {
+		return
+	}
+	logrus.Warnf("Lost connection %s to PMB: %s", s.id, err)
+	s.conn = nil
+	s.lastError = err.Error()
+	select {
+	case s.lost <- struct{}{}:
+	default:
+	}
+}
+
+// connectionStatus is reported by the status endpoint.
+type connectionStatus struct {
+	Connected bool      `json:"connected"`
+	ID        string    `json:"id,omitempty"`
+	Since     time.Time `json:"since,omitempty"`
+	LastError string    `json:"last_error,omitempty"`
+
+	// StuckSends counts sends that timed out and are still blocked.
+	StuckSends int `json:"stuck_sends,omitempty"`
+}
+
+func (s *supervisor) Status() connectionStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := connectionStatus{
+		Connected:  s.conn != nil,
+		LastError:  s.lastError,
+		StuckSends: s.stuck,
+	}
+	if s.conn != nil {
+		status.ID = s.id
+		status.Since = s.since
+	}
+	return status
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/justone/pmb/api"
+)
+
+func TestSupervisorReconnects(t *testing.T) {
+	connects := make(chan string, 10)
+	s := &supervisor{
+		lost: make(chan struct{}, 1),
+		connect: func(id string) (*pmb.Connection, error) {
+			connects <- id
+			if len(connects) == 1 {
+				return nil, errors.New("connection refused")
+			}
+			return &pmb.Connection{}, nil
+		},
+		send: func(conn *pmb.Connection, note pmb.Notification) error {
+			return errors.New("channel closed")
+		},
+		sendTimeout: time.Second,
+		minBackoff:  time.Millisecond,
+		maxBackoff:  time.Millisecond,
+	}
+
+	assert(t, s.Send(pmb.Notification{Message: "first"}) == errNotConnected, "sent without a connection")
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go s.Run(stop)
+
+	waitFor(t, func() bool { return s.Status().Connected })
+	assert(t, len(connects) == 2, "connection not retried")
+
+	assert(t, s.Send(pmb.Notification{Message: "second"}) != nil, "failed send not reported")
+	assert(t, s.Status().LastError == "channel closed", "failure not recorded")
+
+	waitFor(t, func() bool { return len(connects) == 3 && s.Status().Connected })
+}
+
+func TestSupervisorSendTimeout(t *testing.T) {
+	release := make(chan struct{})
+	s := &supervisor{
+		lost: make(chan struct{}, 1),
+		conn: &pmb.Connection{},
+		send: func(conn *pmb.Connection, note pmb.Notification) error {
+			<-release
+			return nil
+		},
+		sendTimeout: time.Millisecond,
+	}
+
+	assert(t, s.Send(pmb.Notification{Message: "stuck"}) != nil, "timeout not reported")
+	assert(t, !s.Status().Connected, "stuck connection not marked dead")
+	assert(t, s.Status().StuckSends == 1, "stuck send not counted")
+
+	close(release)
+	waitFor(t, func() bool { return s.Status().StuckSends == 0 })
+}
+
+func TestSupervisorConnectedWakesOutbox(t *testing.T) {
+	s := &supervisor{
+		lost: make(chan struct{}, 1),
+		connect: func(id string) (*pmb.Connection, error) {
+			return &pmb.Connection{}, nil
+		},
+		send: func(conn *pmb.Connection, note pmb.Notification) error {
+			return nil
+		},
+		sendTimeout: time.Second,
+		minBackoff:  time.Millisecond,
+		maxBackoff:  time.Millisecond,
+	}
+	o, err := openOutbox("", s.Send, time.Hour, time.Hour)
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	s.connected = o.Retry
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go o.Run(stop)
+
+	o.Enqueue(pmb.Notification{Message: "queued while disconnected"})
+	waitFor(t, func() bool { return o.Status().Failures == 1 })
+
+	// The outbox is now backing off for an hour; connecting must cut that
+	// short.
+	go s.Run(stop)
+	waitFor(t, func() bool { return o.Status().Delivered == 1 })
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	for i := 0; i < 100; i++ {
+		if cond() {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("timed out waiting for condition")
+}
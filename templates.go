@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"text/template"
+
+	"github.com/justone/pmb/api"
+	"gopkg.in/yaml.v2"
+)
+
+// messageTemplate holds the text/template sources for a notification. Either
+// field may be left empty to fall back to the built-in template.
+type messageTemplate struct {
+	Message string `yaml:"message"`
+	URL     string `yaml:"url"`
+}
+
+// defaultTemplates are keyed by event name, or by "event:action" to override
+// a single action. The "*" template is used for events with no template.
+var defaultTemplates = map[string]messageTemplate{
+	"*": {
+		Message: "Unhandled event {{.Name}} for {{.Repository.FullName}} by {{.Sender.Login}}.",
+	},
+	"create": {
+		Message: "New {{.RefType}} ({{.Ref}}) for {{.Repository.FullName}} by {{.Sender.Login}}.",
+		URL:     "{{.Repository.HTMLURL}}/tree/{{.Ref}}",
+	},
+	"delete": {
+		Message: "Delete {{.RefType}} ({{branch .Ref}}) for {{.Repository.FullName}} by {{.Sender.Login}}.",
+		URL:     "{{.Repository.HTMLURL}}/tree/{{branch .Ref}}",
+	},
+	"watch": {
+		Message: "New star for {{.Repository.FullName}} by {{.Sender.Login}}.",
+		URL:     "{{.Sender.HTMLURL}}",
+	},
+	"fork": {
+		Message: "New fork for {{.Repository.FullName}} by {{.Sender.Login}}.",
+		URL:     "{{.Forkee.HTMLURL}}",
+	},
+	"push": {
+		Message: "{{if .Forced}}Force-push{{else}}Push{{end}}{{with .Commits}} {{len .}} commit(s){{end}} to {{if .Tag}}tag {{end}}{{.RefName}}" +
+			" in {{.Repository.FullName}} by {{.Sender.Login}}{{with .Authors}} (authors: {{join . \", \"}}){{end}}" +
+			"{{with .HeadCommit}}: {{truncate .Subject 60}}{{else}}.{{end}}{{range .CommitLines}}\n{{.}}{{end}}",
+		URL: "{{.Compare}}",
+	},
+	"pull_request": {
+		Message: "Pull request {{.Action}} #{{.PullRequest.Number}} ({{truncate .PullRequest.Title 20}}) on {{.Repository.FullName}} by {{.Sender.Login}}: {{truncate .PullRequest.Body 40}}",
+		URL:     "{{.PullRequest.HTMLURL}}",
+	},
+	"pull_request:closed": {
+		Message: "{{if .PullRequest.Merged}}Pull request merged #{{.PullRequest.Number}} ({{truncate .PullRequest.Title 20}}){{with .PullRequest.Base}} into {{.Ref}}{{end}}" +
+			" on {{.Repository.FullName}} by {{with .PullRequest.MergedBy}}{{.Login}}{{else}}{{.Sender.Login}}{{end}}." +
+			"{{else}}Pull request closed without merging #{{.PullRequest.Number}} ({{truncate .PullRequest.Title 20}}) on {{.Repository.FullName}} by {{.Sender.Login}}.{{end}}",
+		URL: "{{.PullRequest.HTMLURL}}",
+	},
+	"pull_request:review_requested": {
+		Message: "Review requested from {{.Reviewer}} on pull request #{{.PullRequest.Number}} ({{truncate .PullRequest.Title 20}}) on {{.Repository.FullName}} by {{.Sender.Login}}.",
+		URL:     "{{.PullRequest.HTMLURL}}",
+	},
+	"pull_request:review_request_removed": {
+		Message: "Review request for {{.Reviewer}} removed from pull request #{{.PullRequest.Number}} ({{truncate .PullRequest.Title 20}}) on {{.Repository.FullName}} by {{.Sender.Login}}.",
+		URL:     "{{.PullRequest.HTMLURL}}",
+	},
+	"pull_request:labeled": {
+		Message: "Pull request #{{.PullRequest.Number}} ({{truncate .PullRequest.Title 20}}) labeled {{with .Label}}{{.Name}}{{end}} on {{.Repository.FullName}} by {{.Sender.Login}}.",
+		URL:     "{{.PullRequest.HTMLURL}}",
+	},
+	"pull_request:unlabeled": {
+		Message: "Pull request #{{.PullRequest.Number}} ({{truncate .PullRequest.Title 20}}) unlabeled {{with .Label}}{{.Name}}{{end}} on {{.Repository.FullName}} by {{.Sender.Login}}.",
+		URL:     "{{.PullRequest.HTMLURL}}",
+	},
+	"pull_request:synchronize": {
+		Message: "Pull request #{{.PullRequest.Number}} ({{truncate .PullRequest.Title 20}}) updated to {{shortSHA .After}} on {{.Repository.FullName}} by {{.Sender.Login}}.",
+		URL:     "{{.PullRequest.HTMLURL}}/files",
+	},
+	"pull_request:ready_for_review": {
+		Message: "Pull request #{{.PullRequest.Number}} ({{truncate .PullRequest.Title 20}}) ready for review on {{.Repository.FullName}} by {{.Sender.Login}}.",
+		URL:     "{{.PullRequest.HTMLURL}}",
+	},
+	"pull_request:converted_to_draft": {
+		Message: "Pull request #{{.PullRequest.Number}} ({{truncate .PullRequest.Title 20}}) converted to draft on {{.Repository.FullName}} by {{.Sender.Login}}.",
+		URL:     "{{.PullRequest.HTMLURL}}",
+	},
+	"pull_request:auto_merge_enabled": {
+		Message: "Auto-merge{{with .PullRequest.AutoMerge}}{{with .MergeMethod}} ({{.}}){{end}}{{end}} enabled for pull request #{{.PullRequest.Number}} ({{truncate .PullRequest.Title 20}}) on {{.Repository.FullName}} by {{.Sender.Login}}.",
+		URL:     "{{.PullRequest.HTMLURL}}",
+	},
+	"pull_request:auto_merge_disabled": {
+		Message: "Auto-merge disabled for pull request #{{.PullRequest.Number}} ({{truncate .PullRequest.Title 20}}) on {{.Repository.FullName}} by {{.Sender.Login}}{{with .Reason}}: {{.}}{{end}}.",
+		URL:     "{{.PullRequest.HTMLURL}}",
+	},
+	"issue_comment": {
+		Message: "Comment {{.Action}} on issue {{.Issue.Number}} ({{truncate .Issue.Title 20}}) on {{.Repository.FullName}} by {{.Sender.Login}}: {{truncate .Comment.Body 40}}",
+		URL:     "{{.Comment.HTMLURL}}",
+	},
+	"commit_comment": {
+		Message: "Comment {{.Action}} on commit {{shortSHA .Comment.CommitID}}{{with .Comment.Path}} ({{.}}{{with $.Comment.Line}}:{{.}}{{end}}){{end}} on {{.Repository.FullName}} by {{.Sender.Login}}: {{truncate .Comment.Body 40}}",
+		URL:     "{{.Comment.HTMLURL}}",
+	},
+	"pull_request_review": {
+		Message: "PR Review {{.Action}} ({{.Review.State}}) on issue {{.PullRequest.Number}} ({{truncate .PullRequest.Title 20}}) on {{.Repository.FullName}} by {{.Sender.Login}}: {{truncate .Review.Body 40}}",
+		URL:     "{{.Review.HTMLURL}}",
+	},
+	"pull_request_review_comment": {
+		Message: "PR Comment {{.Action}} on issue {{.PullRequest.Number}} ({{truncate .PullRequest.Title 20}}) on {{.Repository.FullName}} by {{.Sender.Login}}: {{truncate .Comment.Body 40}}",
+		URL:     "{{.Comment.HTMLURL}}",
+	},
+	"issues": {
+		Message: "Issue {{.Issue.Number}} ({{truncate .Issue.Title 20}}) {{.Action}} on {{.Repository.FullName}} by {{.Sender.Login}}.",
+		URL:     "{{.Issue.HTMLURL}}",
+	},
+	"issues:labeled": {
+		Message: "Issue {{.Issue.Number}} ({{truncate .Issue.Title 20}}) labeled {{with .Label}}{{.Name}}{{end}} on {{.Repository.FullName}} by {{.Sender.Login}}.",
+		URL:     "{{.Issue.HTMLURL}}",
+	},
+	"issues:unlabeled": {
+		Message: "Issue {{.Issue.Number}} ({{truncate .Issue.Title 20}}) unlabeled {{with .Label}}{{.Name}}{{end}} on {{.Repository.FullName}} by {{.Sender.Login}}.",
+		URL:     "{{.Issue.HTMLURL}}",
+	},
+	"issues:assigned": {
+		Message: "Issue {{.Issue.Number}} ({{truncate .Issue.Title 20}}) assigned to {{with .Assignee}}{{.Login}}{{end}} on {{.Repository.FullName}} by {{.Sender.Login}}.",
+		URL:     "{{.Issue.HTMLURL}}",
+	},
+	"issues:unassigned": {
+		Message: "Issue {{.Issue.Number}} ({{truncate .Issue.Title 20}}) unassigned from {{with .Assignee}}{{.Login}}{{end}} on {{.Repository.FullName}} by {{.Sender.Login}}.",
+		URL:     "{{.Issue.HTMLURL}}",
+	},
+	"issues:milestoned": {
+		Message: "Issue {{.Issue.Number}} ({{truncate .Issue.Title 20}}) added to milestone {{with .Milestone}}{{.Title}}{{end}} on {{.Repository.FullName}} by {{.Sender.Login}}.",
+		URL:     "{{.Issue.HTMLURL}}",
+	},
+	"issues:demilestoned": {
+		Message: "Issue {{.Issue.Number}} ({{truncate .Issue.Title 20}}) removed from milestone {{with .Milestone}}{{.Title}}{{end}} on {{.Repository.FullName}} by {{.Sender.Login}}.",
+		URL:     "{{.Issue.HTMLURL}}",
+	},
+	"issues:transferred": {
+		Message: "Issue {{.Issue.Number}} ({{truncate .Issue.Title 20}}) transferred from {{.Repository.FullName}}{{with .Changes.NewRepository}} to {{.FullName}}{{end}} by {{.Sender.Login}}.",
+		URL:     "{{with .Changes.NewIssue}}{{.HTMLURL}}{{else}}{{.Issue.HTMLURL}}{{end}}",
+	},
+	"issues:closed": {
+		Message: "Issue {{.Issue.Number}} ({{truncate .Issue.Title 20}}) closed{{with .Issue.Reason}} as {{.}}{{end}} on {{.Repository.FullName}} by {{.Sender.Login}}.",
+		URL:     "{{.Issue.HTMLURL}}",
+	},
+	"ping": {
+		Message: "Ping for {{.Repository.FullName}} by {{.Sender.Login}}. Zen: {{.Zen}}",
+		URL:     "{{.Repository.HTMLURL}}",
+	},
+}
+
+var templateFuncs = template.FuncMap{
+	"truncate": truncate,
+	"shortSHA": shortSHA,
+	"branch":   branch,
+	"join":     strings.Join,
+}
+
+type compiledTemplate struct {
+	message *template.Template
+	url     *template.Template
+}
+
+// templateSet is a compiled set of message templates.
+type templateSet map[string]compiledTemplate
+
+var builtinTemplates = mustCompileTemplates(defaultTemplates)
+
+// registerTemplates adds to the built-in templates. It is meant to be called
+// from the init function of files that register handlers.
+func registerTemplates(defs map[string]messageTemplate) {
+	for key, compiled := range mustCompileTemplates(defs) {
+		builtinTemplates[key] = compiled
+	}
+}
+
+func compileTemplates(defs map[string]messageTemplate) (templateSet, error) {
+	set := make(templateSet)
+	for key, def := range defs {
+		var compiled compiledTemplate
+		var err error
+		if def.Message != "" {
+			compiled.message, err = template.New(key).Funcs(templateFuncs).Parse(def.Message)
+			if err != nil {
+				return nil, fmt.Errorf("Unable to parse message template %s: %s", key, err)
+			}
+		}
+		if def.URL != "" {
+			compiled.url, err = template.New(key).Funcs(templateFuncs).Parse(def.URL)
+			if err != nil {
+				return nil, fmt.Errorf("Unable to parse url template %s: %s", key, err)
+			}
+		}
+		set[key] = compiled
+	}
+	return set, nil
+}
+
+func mustCompileTemplates(defs map[string]messageTemplate) templateSet {
+	set, err := compileTemplates(defs)
+	if err != nil {
+		panic(err)
+	}
+	return set
+}
+
+// loadTemplates reads message templates from a YAML file mapping event or
+// "event:action" keys to a message and url.
+func loadTemplates(path string) (templateSet, error) {
+	if path == "" {
+		return templateSet{}, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read templates: %s", err)
+	}
+	var defs map[string]messageTemplate
+	if err := yaml.UnmarshalStrict(data, &defs); err != nil {
+		return nil, fmt.Errorf("Unable to parse templates: %s", err)
+	}
+	return compileTemplates(defs)
+}
+
+// lookupTemplates finds the template of each kind for an event. Each set is
+// searched for the "event:action" and then the "event" template before the
+// next set is consulted, so that a user's template for an event overrides
+// the built-in templates for all of its actions. The "*" templates are
+// consulted last.
+func lookupTemplates(name, action string, sets ...templateSet) (message, url *template.Template) {
+	keys := []string{name}
+	if action != "" {
+		keys = []string{name + ":" + action, name}
+	}
+	pick := func(set templateSet, key string) {
+		if compiled, ok := set[key]; ok {
+			if message == nil {
+				message = compiled.message
+			}
+			if url == nil {
+				url = compiled.url
+			}
+		}
+	}
+	for _, set := range sets {
+		for _, key := range keys {
+			pick(set, key)
+		}
+	}
+	for _, set := range sets {
+		pick(set, "*")
+	}
+	return message, url
+}
+
+// renderNotification builds the notification for an event from its decoded
+// payload, preferring the configured templates to the built-in ones.
+func renderNotification(name, action string, data interface{}) (*pmb.Notification, error) {
+	message, url := lookupTemplates(name, action, currentSettings().templates, builtinTemplates)
+
+	note := &pmb.Notification{}
+	err := compiledTemplate{message: message, url: url}.render(note, data)
+	if err != nil {
+		return nil, err
+	}
+	return note, nil
+}
+
+// render replaces the message and URL of note with the ones the template
+// defines.
+func (c compiledTemplate) render(note *pmb.Notification, data interface{}) error {
+	var err error
+	if c.message != nil {
+		if note.Message, err = execute(c.message, data); err != nil {
+			return err
+		}
+	}
+	if c.url != nil {
+		if note.URL, err = execute(c.url, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func execute(tmpl *template.Template, data interface{}) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("Unable to render template %s: %s", tmpl.Name(), err)
+	}
+	return buf.String(), nil
+}
+
+func truncate(data string, length int) string {
+	if len(data) > length {
+		return fmt.Sprintf("%s...", data[0:length])
+	}
+	return data
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 7 {
+		return sha[0:7]
+	}
+	return sha
+}
+
+func branch(ref string) string {
+	return strings.TrimPrefix(ref, "refs/heads/")
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestTemplateOverride(t *testing.T) {
+	f, err := ioutil.TempFile("", "templates")
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(`
+pull_request:opened:
+  message: "{{.Sender.Login}} opened {{.Repository.FullName}}#{{.PullRequest.Number}}"
+watch:
+  url: "{{.Repository.HTMLURL}}/stargazers"
+`)
+	f.Close()
+
+	set, err := loadTemplates(f.Name())
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	current.Store(&settings{templates: set})
+	defer current.Store(&settings{})
+
+	note, err := renderNotification("pull_request", "opened", &PullRequestEvent{
+		Event:       Event{Action: "opened", Repository: &Repository{FullName: "baxterthehacker/public-repo"}, Sender: &User{Login: "baxterthehacker"}},
+		PullRequest: &PullRequest{Number: 1, HTMLURL: "https://github.com/baxterthehacker/public-repo/pull/1"},
+	})
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+	assert(t, note.Message == "baxterthehacker opened baxterthehacker/public-repo#1", "Message incorrect")
+	assert(t, note.URL == "https://github.com/baxterthehacker/public-repo/pull/1", "URL not taken from built-in template")
+
+	note, err = renderNotification("watch", "started", &WatchEvent{
+		Event: Event{Action: "started", Repository: &Repository{FullName: "owninguser/repositoryname", HTMLURL: "https://github.com/owninguser/repositoryname"}, Sender: &User{Login: "otheruser"}},
+	})
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+	assert(t, note.Message == "New star for owninguser/repositoryname by otheruser.", "Message not taken from built-in template")
+	assert(t, note.URL == "https://github.com/owninguser/repositoryname/stargazers", "URL incorrect")
+}
+
+func TestTemplateParseError(t *testing.T) {
+	_, err := compileTemplates(map[string]messageTemplate{"push": {Message: "{{.Ref"}})
+
+	assert(t, err != nil, "invalid template accepted")
+}
+
+func TestTemplateUnknownKey(t *testing.T) {
+	f, err := ioutil.TempFile("", "templates")
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("watch:\n  mesage: \"Star from {{.Sender.Login}}\"\n")
+	f.Close()
+
+	_, err = loadTemplates(f.Name())
+
+	assert(t, err != nil, "misspelled template key accepted")
+}
+
+func TestTemplateFuncs(t *testing.T) {
+	assert(t, shortSHA("0d1a26e67d8f5eaf1f6ba5c57fc3c7d91ac0fd1c") == "0d1a26e", "shortSHA incorrect")
+	assert(t, branch("refs/heads/changes") == "changes", "branch incorrect")
+	assert(t, truncate("Spelling error in the README file", 20) == "Spelling error in th...", "truncate incorrect")
+}
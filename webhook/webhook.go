@@ -0,0 +1,84 @@
+// Package webhook holds the registry of GitHub webhook event handlers, so
+// that handlers can be added by packages other than pmb-gh itself. Such a
+// package registers its handlers from an init function, and is linked in
+// with a blank import in pmb-gh's main package.
+package webhook
+
+import (
+	"sync"
+
+	"github.com/justone/pmb/api"
+)
+
+// Metadata describes the delivery a notification was built from, so that it
+// can be filtered and routed without knowing the shape of each payload.
+type Metadata struct {
+	Event      string
+	Action     string
+	Repository string
+	Sender     string
+	SenderType string
+
+	// Ref is the branch or tag the event concerns, without the refs/heads/
+	// prefix. For pull requests it is the branch they target.
+	Ref    string
+	Labels []string
+
+	// State qualifies the action where GitHub reports the outcome
+	// separately, such as the state of a submitted review.
+	State string
+
+	// Environment is the deployment environment, for deployment events.
+	Environment string
+
+	// Severity is low, medium, high or critical for security alerts.
+	Severity string
+
+	// Paths are the files added, modified or removed by a push.
+	Paths []string
+
+	// Data is the decoded payload the notification was rendered from, if
+	// it was rendered from a template.
+	Data interface{}
+}
+
+// EventHandler turns the payload of a GitHub event into a notification.
+// Returning a nil notification without an error skips the delivery.
+type EventHandler interface {
+	Handle(name string, payload []byte) (*pmb.Notification, *Metadata, error)
+}
+
+// EventHandlerFunc adapts an ordinary function to an EventHandler.
+type EventHandlerFunc func(name string, payload []byte) (*pmb.Notification, *Metadata, error)
+
+func (f EventHandlerFunc) Handle(name string, payload []byte) (*pmb.Notification, *Metadata, error) {
+	return f(name, payload)
+}
+
+var (
+	handlersMu sync.RWMutex
+	handlers   = make(map[string]EventHandler)
+)
+
+// Register sets the handler for the named event, replacing any handler
+// already registered for it.
+func Register(name string, handler EventHandler) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	handlers[name] = handler
+}
+
+// Unregister removes the handler for the named event, if any.
+func Unregister(name string) {
+	handlersMu.Lock()
+	defer handlersMu.Unlock()
+	delete(handlers, name)
+}
+
+// Lookup returns the handler registered for the named event.
+func Lookup(name string) (EventHandler, bool) {
+	handlersMu.RLock()
+	defer handlersMu.RUnlock()
+	handler, ok := handlers[name]
+	return handler, ok
+}
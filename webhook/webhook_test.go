@@ -0,0 +1,30 @@
+package webhook
+
+import (
+	"testing"
+
+	"github.com/justone/pmb/api"
+)
+
+func TestRegister(t *testing.T) {
+	Register("internal_audit", EventHandlerFunc(func(name string, payload []byte) (*pmb.Notification, *Metadata, error) {
+		return &pmb.Notification{Message: "Audit finished"}, &Metadata{Action: "finished"}, nil
+	}))
+
+	handler, ok := Lookup("internal_audit")
+	if !ok {
+		t.Fatalf("Error: handler not registered")
+	}
+	note, meta, err := handler.Handle("internal_audit", []byte(`{}`))
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+	if note.Message != "Audit finished" || meta.Action != "finished" {
+		t.Errorf("Error: wrong handler returned")
+	}
+
+	Unregister("internal_audit")
+	if _, ok := Lookup("internal_audit"); ok {
+		t.Errorf("Error: handler not unregistered")
+	}
+}
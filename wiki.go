@@ -0,0 +1,58 @@
+package main
+
+func init() {
+	RegisterHandler("gollum", payloadHandler(func() eventPayload { return &GollumEvent{} }))
+	registerTemplates(map[string]messageTemplate{
+		"gollum": {
+			Message: "Wiki for {{.Repository.FullName}} updated by {{.Sender.Login}}:" +
+				"{{with .Created}} created {{join . \", \"}}{{end}}{{if and .Created .Edited}};{{end}}" +
+				"{{with .Edited}} edited {{join . \", \"}}{{end}}.",
+			URL: "{{.URL}}",
+		},
+	})
+}
+
+// WikiPage is a wiki page changed in a gollum event.
+type WikiPage struct {
+	PageName string `json:"page_name"`
+	Title    string `json:"title" required:"true"`
+	Summary  string `json:"summary"`
+	Action   string `json:"action" required:"true"`
+	SHA      string `json:"sha"`
+	HTMLURL  string `json:"html_url"`
+}
+
+// GollumEvent is the payload of gollum events, sent when wiki pages are
+// created or edited. A single delivery can cover several pages.
+type GollumEvent struct {
+	Event
+	Pages []WikiPage `json:"pages" required:"true"`
+}
+
+// Created lists the titles of the pages that were created.
+func (e *GollumEvent) Created() []string {
+	return e.titles("created")
+}
+
+// Edited lists the titles of the pages that were edited.
+func (e *GollumEvent) Edited() []string {
+	return e.titles("edited")
+}
+
+func (e *GollumEvent) titles(action string) []string {
+	var titles []string
+	for _, page := range e.Pages {
+		if page.Action == action {
+			titles = append(titles, page.Title)
+		}
+	}
+	return titles
+}
+
+// URL links to the page if only one changed, or to the wiki otherwise.
+func (e *GollumEvent) URL() string {
+	if len(e.Pages) == 1 && e.Pages[0].HTMLURL != "" {
+		return e.Pages[0].HTMLURL
+	}
+	return e.Repository.HTMLURL + "/wiki"
+}
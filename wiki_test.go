@@ -0,0 +1,29 @@
+package main
+
+import "testing"
+
+func TestGollum(t *testing.T) {
+	name := "gollum"
+	json := `{"pages":[{"page_name":"Home","title":"Home","summary":null,"action":"created","sha":"6bf911d3801dd1ef957fc6ade5a8b96429e3fa39","html_url":"https://github.com/Codertocat/Hello-World/wiki/Home"},{"page_name":"Setup","title":"Setup","summary":null,"action":"edited","sha":"91ea1bd42aa2ba166b86e8aefe049e9837214e67","html_url":"https://github.com/Codertocat/Hello-World/wiki/Setup"},{"page_name":"FAQ","title":"FAQ","summary":null,"action":"created","sha":"ab4e9a5b1d1ac13c4e6c3f0c7f7e9f3b50a3c1d2","html_url":"https://github.com/Codertocat/Hello-World/wiki/FAQ"}],"repository":{"id":186853002,"name":"Hello-World","full_name":"Codertocat/Hello-World","private":false,"owner":{"login":"Codertocat","id":21031067,"type":"User"},"html_url":"https://github.com/Codertocat/Hello-World"},"sender":{"login":"rachmari","id":9831992,"html_url":"https://github.com/rachmari","type":"User","site_admin":false}}`
+	note, _, err := parseEvent(name, json)
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+
+	assert(t, note.Message == "Wiki for Codertocat/Hello-World updated by rachmari: created Home, FAQ; edited Setup.", "Message incorrect")
+	assert(t, note.URL == "https://github.com/Codertocat/Hello-World/wiki", "URL incorrect")
+}
+
+func TestGollumSinglePage(t *testing.T) {
+	name := "gollum"
+	json := `{"pages":[{"page_name":"Home","title":"Home","summary":null,"action":"edited","sha":"6bf911d3801dd1ef957fc6ade5a8b96429e3fa39","html_url":"https://github.com/Codertocat/Hello-World/wiki/Home"}],"repository":{"id":186853002,"name":"Hello-World","full_name":"Codertocat/Hello-World","html_url":"https://github.com/Codertocat/Hello-World"},"sender":{"login":"rachmari","id":9831992,"type":"User"}}`
+	note, _, err := parseEvent(name, json)
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+
+	assert(t, note.Message == "Wiki for Codertocat/Hello-World updated by rachmari: edited Home.", "Message incorrect")
+	assert(t, note.URL == "https://github.com/Codertocat/Hello-World/wiki/Home", "URL incorrect")
+}
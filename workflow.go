@@ -0,0 +1,142 @@
+package main
+
+import (
+	"time"
+)
+
+func init() {
+	RegisterHandler("workflow_run", templateHandler(decodeWorkflowRun))
+	RegisterHandler("workflow_job", templateHandler(decodeWorkflowJob))
+	registerTemplates(map[string]messageTemplate{
+		"workflow_run": {
+			Message: "Workflow {{.WorkflowRun.Name}} {{or .WorkflowRun.Conclusion .WorkflowRun.Status}} on {{.WorkflowRun.HeadBranch}} ({{shortSHA .WorkflowRun.HeadSHA}})" +
+				" in {{.Repository.FullName}}{{with .WorkflowRun.Duration}} after {{.}}{{end}}.",
+			URL: "{{.WorkflowRun.HTMLURL}}",
+		},
+		"workflow_job": {
+			Message: "Job {{.WorkflowJob.Name}}{{with .WorkflowJob.WorkflowName}} ({{.}}){{end}} {{or .WorkflowJob.Conclusion .WorkflowJob.Status}}" +
+				" on {{.WorkflowJob.HeadBranch}} ({{shortSHA .WorkflowJob.HeadSHA}}) in {{.Repository.FullName}}{{with .WorkflowJob.Duration}} after {{.}}{{end}}.",
+			URL: "{{.WorkflowJob.HTMLURL}}",
+		},
+	})
+}
+
+// WorkflowRun is a run of a GitHub Actions workflow.
+type WorkflowRun struct {
+	ID           int64     `json:"id"`
+	Name         string    `json:"name" required:"true"`
+	RunNumber    int       `json:"run_number"`
+	Event        string    `json:"event"`
+	Status       string    `json:"status" required:"true"`
+	Conclusion   string    `json:"conclusion"`
+	HeadBranch   string    `json:"head_branch"`
+	HeadSHA      string    `json:"head_sha"`
+	HTMLURL      string    `json:"html_url" required:"true"`
+	CreatedAt    time.Time `json:"created_at"`
+	RunStartedAt time.Time `json:"run_started_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// Duration is how long a completed run took, or empty if it hasn't
+// completed.
+func (r *WorkflowRun) Duration() string {
+	started := r.RunStartedAt
+	if started.IsZero() {
+		started = r.CreatedAt
+	}
+	if r.Status != "completed" {
+		return ""
+	}
+	return duration(started, r.UpdatedAt)
+}
+
+// WorkflowRunEvent is the payload of workflow_run events.
+type WorkflowRunEvent struct {
+	Event
+	WorkflowRun *WorkflowRun `json:"workflow_run" required:"true"`
+}
+
+func (e *WorkflowRunEvent) describe(meta *Metadata) {
+	meta.Ref = e.WorkflowRun.HeadBranch
+	meta.State = e.WorkflowRun.Conclusion
+}
+
+// WorkflowJob is a job within a workflow run.
+type WorkflowJob struct {
+	ID           int64     `json:"id"`
+	RunID        int64     `json:"run_id"`
+	Name         string    `json:"name" required:"true"`
+	WorkflowName string    `json:"workflow_name"`
+	Status       string    `json:"status" required:"true"`
+	Conclusion   string    `json:"conclusion"`
+	HeadBranch   string    `json:"head_branch"`
+	HeadSHA      string    `json:"head_sha"`
+	HTMLURL      string    `json:"html_url" required:"true"`
+	StartedAt    time.Time `json:"started_at"`
+	CompletedAt  time.Time `json:"completed_at"`
+}
+
+// Duration is how long a completed job took, or empty if it hasn't
+// completed.
+func (j *WorkflowJob) Duration() string {
+	if j.Status != "completed" {
+		return ""
+	}
+	return duration(j.StartedAt, j.CompletedAt)
+}
+
+// WorkflowJobEvent is the payload of workflow_job events.
+type WorkflowJobEvent struct {
+	Event
+	WorkflowJob *WorkflowJob `json:"workflow_job" required:"true"`
+}
+
+func (e *WorkflowJobEvent) describe(meta *Metadata) {
+	meta.Ref = e.WorkflowJob.HeadBranch
+	meta.State = e.WorkflowJob.Conclusion
+}
+
+func duration(start, end time.Time) string {
+	if start.IsZero() || end.IsZero() || end.Before(start) {
+		return ""
+	}
+	return end.Sub(start).String()
+}
+
+// notifyWorkflow reports whether a workflow run or job with the given action
+// and conclusion should be notified about. By default only completed runs
+// that didn't succeed are.
+func notifyWorkflow(action, conclusion string) bool {
+	config := currentSettings()
+	if !config.workflowActions[action] && !config.workflowActions["*"] {
+		return false
+	}
+	if action != "completed" {
+		return true
+	}
+	return config.workflowConclusions[conclusion] || config.workflowConclusions["*"]
+}
+
+func decodeWorkflowRun(payload []byte) (interface{}, *Metadata, error) {
+	var event WorkflowRunEvent
+	if err := decodeEvent(payload, &event); err != nil {
+		return nil, nil, err
+	}
+	meta := newMetadata(event.Event)
+	if !notifyWorkflow(event.Action, event.WorkflowRun.Conclusion) {
+		return nil, meta, nil
+	}
+	return &event, meta, nil
+}
+
+func decodeWorkflowJob(payload []byte) (interface{}, *Metadata, error) {
+	var event WorkflowJobEvent
+	if err := decodeEvent(payload, &event); err != nil {
+		return nil, nil, err
+	}
+	meta := newMetadata(event.Event)
+	if !notifyWorkflow(event.Action, event.WorkflowJob.Conclusion) {
+		return nil, meta, nil
+	}
+	return &event, meta, nil
+}
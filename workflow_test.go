@@ -0,0 +1,79 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+const workflowRunJSON = `{"action":"completed","workflow_run":{"id":30433642,"name":"Build","node_id":"MDEyOldvcmtmbG93IFJ1bjI2OTI4OQ==","head_branch":"main","head_sha":"acb5820ced9479c074f688cc328bf03f341a511d","path":".github/workflows/build.yml","display_title":"Update README.md","run_number":562,"event":"push","status":"completed","conclusion":"failure","workflow_id":159038,"check_suite_id":414944374,"check_suite_node_id":"MDEwOkNoZWNrU3VpdGU0MTQ5NDQzNzQ=","url":"https://api.github.com/repos/octo-org/octo-repo/actions/runs/30433642","html_url":"https://github.com/octo-org/octo-repo/actions/runs/30433642","pull_requests":[],"created_at":"2020-01-22T19:33:08Z","updated_at":"2020-01-22T19:36:20Z","run_attempt":1,"run_started_at":"2020-01-22T19:33:08Z","jobs_url":"https://api.github.com/repos/octo-org/octo-repo/actions/runs/30433642/jobs","logs_url":"https://api.github.com/repos/octo-org/octo-repo/actions/runs/30433642/logs","check_suite_url":"https://api.github.com/repos/octo-org/octo-repo/check-suites/414944374","artifacts_url":"https://api.github.com/repos/octo-org/octo-repo/actions/runs/30433642/artifacts","cancel_url":"https://api.github.com/repos/octo-org/octo-repo/actions/runs/30433642/cancel","rerun_url":"https://api.github.com/repos/octo-org/octo-repo/actions/runs/30433642/rerun","workflow_url":"https://api.github.com/repos/octo-org/octo-repo/actions/workflows/159038","head_commit":{"id":"acb5820ced9479c074f688cc328bf03f341a511d","tree_id":"d23f6eedb1e1b9610bbc754ddb5197bfe7271223","message":"Update README.md","timestamp":"2020-01-22T19:33:05Z","author":{"name":"Octo Cat","email":"octocat@github.com"},"committer":{"name":"GitHub","email":"noreply@github.com"}},"repository":{"id":1296269,"name":"octo-repo","full_name":"octo-org/octo-repo"},"head_repository":{"id":1296269,"name":"octo-repo","full_name":"octo-org/octo-repo"}},"workflow":{"id":159038,"node_id":"MDg6V29ya2Zsb3cxNTkwMzg=","name":"Build","path":".github/workflows/build.yml","state":"active","created_at":"2020-01-08T23:48:37Z","updated_at":"2020-01-08T23:50:21Z","url":"https://api.github.com/repos/octo-org/octo-repo/actions/workflows/159038","html_url":"https://github.com/octo-org/octo-repo/blob/main/.github/workflows/build.yml","badge_url":"https://github.com/octo-org/octo-repo/workflows/Build/badge.svg"},"repository":{"id":1296269,"node_id":"MDEwOlJlcG9zaXRvcnkxMjk2MjY5","name":"octo-repo","full_name":"octo-org/octo-repo","private":true,"owner":{"login":"octo-org","id":6811672,"node_id":"MDEyOk9yZ2FuaXphdGlvbjY4MTE2NzI=","avatar_url":"https://avatars.githubusercontent.com/u/6811672?v=4","html_url":"https://github.com/octo-org","type":"Organization","site_admin":false},"html_url":"https://github.com/octo-org/octo-repo","description":"","fork":false,"url":"https://api.github.com/repos/octo-org/octo-repo","default_branch":"main"},"organization":{"login":"octo-org","id":6811672,"node_id":"MDEyOk9yZ2FuaXphdGlvbjY4MTE2NzI=","url":"https://api.github.com/orgs/octo-org","avatar_url":"https://avatars.githubusercontent.com/u/6811672?v=4","description":"Working better together!"},"sender":{"login":"octocat","id":583231,"node_id":"MDQ6VXNlcjU4MzIzMQ==","avatar_url":"https://avatars.githubusercontent.com/u/583231?v=4","gravatar_id":"","url":"https://api.github.com/users/octocat","html_url":"https://github.com/octocat","type":"User","site_admin":false}}`
+
+const workflowJobJSON = `{"action":"completed","workflow_job":{"id":2832853555,"run_id":940463255,"workflow_name":"Build","head_branch":"main","run_url":"https://api.github.com/repos/octo-org/example-workflow/actions/runs/940463255","run_attempt":1,"node_id":"MDg6Q2hlY2tSdW4yODMyODUzNTU1","head_sha":"e3103f8eb03e1ad7f2331c5446b23c070fc54055","url":"https://api.github.com/repos/octo-org/example-workflow/actions/jobs/2832853555","html_url":"https://github.com/octo-org/example-workflow/runs/2832853555","status":"completed","conclusion":"failure","started_at":"2021-06-15T19:22:27Z","completed_at":"2021-06-15T19:22:38Z","name":"Test workflow","steps":[{"name":"Set up job","status":"completed","conclusion":"success","number":1,"started_at":"2021-06-15T19:22:27.000Z","completed_at":"2021-06-15T19:22:29.000Z"},{"name":"Run tests","status":"completed","conclusion":"failure","number":2,"started_at":"2021-06-15T19:22:29.000Z","completed_at":"2021-06-15T19:22:38.000Z"}],"check_run_url":"https://api.github.com/repos/octo-org/example-workflow/check-runs/2832853555","labels":["gpu","db-app","dc-03"],"runner_id":1,"runner_name":"my runner","runner_group_id":2,"runner_group_name":"my runner group"},"repository":{"id":376034443,"node_id":"MDEwOlJlcG9zaXRvcnkzNzYwMzQ0NDM=","name":"example-workflow","full_name":"octo-org/example-workflow","private":true,"owner":{"login":"octo-org","id":33435682,"node_id":"MDEyOk9yZ2FuaXphdGlvbjMzNDM1Njgy","avatar_url":"https://avatars.githubusercontent.com/u/33435682?v=4","html_url":"https://github.com/octo-org","type":"Organization","site_admin":false},"html_url":"https://github.com/octo-org/example-workflow","description":"Test workflow","fork":false,"url":"https://api.github.com/repos/octo-org/example-workflow","default_branch":"main"},"organization":{"login":"octo-org","id":33435682,"node_id":"MDEyOk9yZ2FuaXphdGlvbjMzNDM1Njgy","url":"https://api.github.com/orgs/octo-org","description":"octo-org"},"sender":{"login":"octocat","id":319655,"node_id":"MDQ6VXNlcjMxOTY1NQ==","avatar_url":"https://avatars.githubusercontent.com/u/21031067?s=460&u=d851e01410b4f1674f000ba7e0dc686b2a9e5ff4&v=4","gravatar_id":"","url":"https://api.github.com/users/octocat","html_url":"https://github.com/octocat","type":"User","site_admin":true}}`
+
+func withDefaultSettings(t *testing.T) func() {
+	s, err := loadSettings("")
+	if err != nil {
+		t.Fatalf("Error: %s", err)
+	}
+	current.Store(s)
+	return func() { current.Store(&settings{}) }
+}
+
+func TestWorkflowRun(t *testing.T) {
+	defer withDefaultSettings(t)()
+
+	name := "workflow_run"
+	note, meta, err := parseEvent(name, workflowRunJSON)
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+
+	assert(t, note.Message == "Workflow Build failure on main (acb5820) in octo-org/octo-repo after 3m12s.", "Message incorrect")
+	assert(t, note.URL == "https://github.com/octo-org/octo-repo/actions/runs/30433642", "URL incorrect")
+	assert(t, meta.Ref == "main" && meta.State == "failure", "Metadata incorrect")
+}
+
+func TestWorkflowRunSuccessSkipped(t *testing.T) {
+	defer withDefaultSettings(t)()
+
+	note, _, err := parseEvent("workflow_run", strings.Replace(workflowRunJSON, `"conclusion":"failure"`, `"conclusion":"success"`, 1))
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+	assert(t, note == nil, "successful run notified")
+
+	note, _, err = parseEvent("workflow_run", strings.Replace(workflowRunJSON, `"action":"completed"`, `"action":"requested"`, 1))
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+	assert(t, note == nil, "requested run notified")
+}
+
+func TestWorkflowRunAllConclusions(t *testing.T) {
+	opts.WorkflowConclusions = []string{"*"}
+	defer func() { opts.WorkflowConclusions = nil }()
+	defer withDefaultSettings(t)()
+
+	note, _, err := parseEvent("workflow_run", strings.Replace(workflowRunJSON, `"conclusion":"failure"`, `"conclusion":"success"`, 1))
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+	assert(t, note != nil && note.Message == "Workflow Build success on main (acb5820) in octo-org/octo-repo after 3m12s.", "Message incorrect")
+}
+
+func TestWorkflowJob(t *testing.T) {
+	defer withDefaultSettings(t)()
+
+	name := "workflow_job"
+	note, _, err := parseEvent(name, workflowJobJSON)
+
+	if err != nil {
+		t.Errorf("Error: %s", err)
+	}
+
+	assert(t, note.Message == "Job Test workflow (Build) failure on main (e3103f8) in octo-org/example-workflow after 11s.", "Message incorrect")
+	assert(t, note.URL == "https://github.com/octo-org/example-workflow/runs/2832853555", "URL incorrect")
+}